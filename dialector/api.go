@@ -0,0 +1,193 @@
+package dialector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"github.com/influxdata/line-protocol/v2/lineprotocol"
+)
+
+// host 返回 HTTP API 的主机地址，优先使用 ClientOpts 中的配置
+func (dialector *Dialector) host() string {
+	if dialector.ClientOpts != nil && dialector.ClientOpts.Host != "" {
+		return dialector.ClientOpts.Host
+	}
+	return dialector.Host
+}
+
+// database 返回当前使用的数据库名称
+func (dialector *Dialector) database() string {
+	if dialector.ClientOpts != nil && dialector.ClientOpts.Database != "" {
+		return dialector.ClientOpts.Database
+	}
+	return dialector.Database
+}
+
+// authorization 返回 HTTP API 的认证头
+func (dialector *Dialector) authorization() string {
+	scheme, token := "Token", dialector.Token
+	if dialector.ClientOpts != nil {
+		if dialector.ClientOpts.AuthScheme != "" {
+			scheme = dialector.ClientOpts.AuthScheme
+		}
+		if dialector.ClientOpts.Token != "" {
+			token = dialector.ClientOpts.Token
+		}
+	}
+	if token == "" {
+		return ""
+	}
+	return scheme + " " + token
+}
+
+// httpClient 返回用于调用 HTTP API 的客户端
+func (dialector *Dialector) httpClient() *http.Client {
+	if dialector.ClientOpts != nil && dialector.ClientOpts.HTTPClient != nil {
+		return dialector.ClientOpts.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// callAPI 调用 InfluxDB 3 的 HTTP 管理接口
+// body 不为空时以 JSON 格式发送，服务端返回非 2xx 状态码时返回 *influxdb3.ServerError
+func (dialector *Dialector) callAPI(ctx context.Context, method, path string, params url.Values, body any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	return dialector.sendAPI(ctx, method, path, params, "application/json", data)
+}
+
+// writeLineProtocol 通过 v3 写入接口写入行协议数据
+// 未开启 AcceptPartial 时任何一行被拒绝都不会写入本批次的数据，服务端拒绝的行通过 *PartialWriteError 返回
+func (dialector *Dialector) writeLineProtocol(ctx context.Context, data []byte, precision lineprotocol.Precision) error {
+	params := url.Values{}
+	params.Set("db", dialector.database())
+	params.Set("precision", precisionParam(precision))
+	params.Set("accept_partial", strconv.FormatBool(dialector.AcceptPartial))
+	return dialector.sendAPI(ctx, http.MethodPost, "/api/v3/write_lp", params, "text/plain; charset=utf-8", data)
+}
+
+// precisionParam 返回 v3 写入接口使用的精度参数
+func precisionParam(precision lineprotocol.Precision) string {
+	switch precision {
+	case lineprotocol.Second:
+		return "second"
+	case lineprotocol.Millisecond:
+		return "millisecond"
+	case lineprotocol.Microsecond:
+		return "microsecond"
+	default:
+		return "nanosecond"
+	}
+}
+
+// sendAPI 发送 HTTP 请求，data 为空时不发送请求体
+func (dialector *Dialector) sendAPI(ctx context.Context, method, path string, params url.Values, contentType string, data []byte) error {
+	host := dialector.host()
+	if host == "" {
+		return errors.New("InfluxDB主机地址为空")
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(host, "/") + path)
+	if err != nil {
+		return fmt.Errorf("parsing host URL: %w", err)
+	}
+	if len(params) > 0 {
+		u.RawQuery = params.Encode()
+	}
+
+	var reader io.Reader
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if auth := dialector.authorization(); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	resp, err := dialector.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return apiError(resp)
+	}
+	return nil
+}
+
+// apiError 解析服务端返回的错误
+// InfluxDB 3 的错误格式为 {"error": "...", "data": ...}，部分写入失败时 data 为被拒绝的行
+func apiError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	serverErr := &influxdb3.ServerError{StatusCode: resp.StatusCode, Headers: resp.Header}
+
+	var payload struct {
+		Code    string          `json:"code"`
+		Message string          `json:"message"`
+		Error   string          `json:"error"`
+		Data    json.RawMessage `json:"data"`
+	}
+	var lines []struct {
+		OriginalLine string `json:"original_line"`
+		LineNumber   int    `json:"line_number"`
+		ErrorMessage string `json:"error_message"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		serverErr.Code = payload.Code
+		serverErr.Message = payload.Message
+		if serverErr.Message == "" {
+			serverErr.Message = payload.Error
+		}
+		if len(payload.Data) > 0 && payload.Data[0] == '{' {
+			var line struct {
+				ErrorMessage string `json:"error_message"`
+			}
+			if json.Unmarshal(payload.Data, &line) == nil && line.ErrorMessage != "" {
+				serverErr.Message = line.ErrorMessage
+			}
+		} else if len(payload.Data) > 0 {
+			_ = json.Unmarshal(payload.Data, &lines)
+		}
+	}
+	if serverErr.Message == "" {
+		serverErr.Message = strings.TrimSpace(string(body))
+	}
+	if serverErr.Message == "" {
+		serverErr.Message = resp.Status
+	}
+
+	if len(lines) == 0 {
+		return serverErr
+	}
+	partialErr := &PartialWriteError{Err: serverErr}
+	for _, line := range lines {
+		partialErr.Lines = append(partialErr.Lines, WriteLineError{
+			Line:    line.LineNumber,
+			Text:    line.OriginalLine,
+			Message: line.ErrorMessage,
+		})
+	}
+	return partialErr
+}
@@ -0,0 +1,157 @@
+package dialector
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+)
+
+// arrowValue 读取 Arrow 列中指定行的值并转换为 Go 值
+// 时间戳按列类型中的单位和时区转换为 time.Time，loc 不为空时再转换到该时区，NULL 返回 nil。
+// 字典编码的列返回字典中的值，列表返回切片，struct 返回以字段名为键的 map[string]interface{}，
+// 其他类型返回 Arrow 的字符串形式
+func arrowValue(col arrow.Array, row int, loc *time.Location) (interface{}, error) {
+	if col.IsNull(row) {
+		return nil, nil
+	}
+
+	switch arr := col.(type) {
+	case *array.Boolean:
+		return arr.Value(row), nil
+	case *array.Int8:
+		return int64(arr.Value(row)), nil
+	case *array.Int16:
+		return int64(arr.Value(row)), nil
+	case *array.Int32:
+		return int64(arr.Value(row)), nil
+	case *array.Int64:
+		return arr.Value(row), nil
+	case *array.Uint8:
+		return uint64(arr.Value(row)), nil
+	case *array.Uint16:
+		return uint64(arr.Value(row)), nil
+	case *array.Uint32:
+		return uint64(arr.Value(row)), nil
+	case *array.Uint64:
+		return arr.Value(row), nil
+	case *array.Float16:
+		return float64(arr.Value(row).Float32()), nil
+	case *array.Float32:
+		return float64(arr.Value(row)), nil
+	case *array.Float64:
+		return arr.Value(row), nil
+	case *array.String:
+		return arr.Value(row), nil
+	case *array.LargeString:
+		return arr.Value(row), nil
+	case *array.Binary:
+		return arr.Value(row), nil
+	case *array.LargeBinary:
+		return arr.Value(row), nil
+	case *array.Timestamp:
+		toTime, err := arr.DataType().(*arrow.TimestampType).GetToTimeFunc()
+		if err != nil {
+			return nil, err
+		}
+		if loc != nil {
+			return toTime(arr.Value(row)).In(loc), nil
+		}
+		return toTime(arr.Value(row)), nil
+	case *array.Dictionary:
+		return arrowValue(arr.Dictionary(), arr.GetValueIndex(row), loc)
+	case *array.Struct:
+		fields := arr.DataType().(*arrow.StructType).Fields()
+		value := make(map[string]interface{}, len(fields))
+		for i, field := range fields {
+			v, err := arrowValue(arr.Field(i), row, loc)
+			if err != nil {
+				return nil, err
+			}
+			value[field.Name] = v
+		}
+		return value, nil
+	case array.ListLike:
+		return listValue(arr, row, loc)
+	}
+
+	// Date32、Decimal128、Duration 等其他类型按 Arrow 的字符串形式返回
+	return col.ValueStr(row), nil
+}
+
+// listValue 将列表转换为切片，切片的类型由元素的 Arrow 类型决定，如 List(Utf8) 返回 []string，
+// 便于 database/sql 直接扫描到同类型的切片；包含 NULL 或无法确定类型的元素时返回 []interface{}
+func listValue(arr array.ListLike, row int, loc *time.Location) (interface{}, error) {
+	start, end := arr.ValueOffsets(row)
+	items := make([]interface{}, 0, end-start)
+	for i := start; i < end; i++ {
+		v, err := arrowValue(arr.ListValues(), int(i), loc)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+
+	elemType := goType(arr.DataType().(arrow.ListLikeType).Elem())
+	if elemType == nil {
+		return items, nil
+	}
+	slice := reflect.MakeSlice(reflect.SliceOf(elemType), len(items), len(items))
+	for i, item := range items {
+		if item == nil || reflect.TypeOf(item) != elemType {
+			return items, nil
+		}
+		slice.Index(i).Set(reflect.ValueOf(item))
+	}
+	return slice.Interface(), nil
+}
+
+// goType 返回 arrowValue 对该 Arrow 类型返回的 Go 类型，无法确定时返回 nil
+func goType(t arrow.DataType) reflect.Type {
+	switch t.ID() {
+	case arrow.BOOL:
+		return reflect.TypeOf(false)
+	case arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64:
+		return reflect.TypeOf(int64(0))
+	case arrow.UINT8, arrow.UINT16, arrow.UINT32, arrow.UINT64:
+		return reflect.TypeOf(uint64(0))
+	case arrow.FLOAT16, arrow.FLOAT32, arrow.FLOAT64:
+		return reflect.TypeOf(float64(0))
+	case arrow.STRING, arrow.LARGE_STRING:
+		return reflect.TypeOf("")
+	case arrow.BINARY, arrow.LARGE_BINARY:
+		return reflect.TypeOf([]byte(nil))
+	case arrow.TIMESTAMP:
+		return reflect.TypeOf(time.Time{})
+	case arrow.DICTIONARY:
+		return goType(t.(*arrow.DictionaryType).ValueType)
+	case arrow.STRUCT:
+		return reflect.TypeOf(map[string]interface{}(nil))
+	case arrow.LIST, arrow.LARGE_LIST, arrow.FIXED_SIZE_LIST:
+		if elem := goType(t.(arrow.ListLikeType).Elem()); elem != nil {
+			return reflect.SliceOf(elem)
+		}
+	}
+	return nil
+}
+
+// columnTypeName 返回列的类型名称，InfluxDB 3 在列的元数据中标记 tag 和时间戳
+func columnTypeName(field arrow.Field) string {
+	if isTagColumn(field) {
+		return "TAG"
+	}
+	if field.Type.ID() == arrow.TIMESTAMP {
+		return "TIMESTAMP"
+	}
+	return strings.ToUpper(field.Type.Name())
+}
+
+// isTagColumn 判断列是否为 tag，没有元数据时字典编码的字符串列视为 tag
+func isTagColumn(field arrow.Field) bool {
+	if columnType, ok := field.Metadata.GetValue("iox::column::type"); ok {
+		return columnType == "iox::column_type::tag"
+	}
+	return field.Type.ID() == arrow.DICTIONARY
+}
@@ -0,0 +1,336 @@
+package dialector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LastCacheOptions 创建 Last Value Cache 的选项
+type LastCacheOptions struct {
+	Name         string        // 缓存名称，为空时由服务端生成
+	KeyColumns   []string      // 键列，为空时使用模型的 tag 字段
+	ValueColumns []string      // 值列，为空时缓存所有非键列
+	Count        int           // 每个键保留的最新值数量，为 0 时使用服务端默认值
+	TTL          time.Duration // 缓存值的过期时间，为 0 时使用服务端默认值
+}
+
+// DistinctCacheOptions 创建 Distinct Value Cache 的选项
+type DistinctCacheOptions struct {
+	Name           string        // 缓存名称，为空时由服务端生成
+	Columns        []string      // 缓存的列，按层级顺序排列，为空时使用模型的 tag 字段
+	MaxCardinality int           // 最大基数，为 0 时使用服务端默认值
+	MaxAge         time.Duration // 值的最大保留时间，为 0 时使用服务端默认值
+}
+
+// LastCache Last Value Cache 的定义
+type LastCache struct {
+	Table        string
+	Name         string
+	KeyColumns   []string
+	ValueColumns []string
+	Count        int64
+	TTL          time.Duration
+}
+
+// influxDialector 返回迁移器对应的 InfluxDB3 方言
+func (m Migrator) influxDialector() (*Dialector, error) {
+	dialector, ok := m.Dialector.(*Dialector)
+	if !ok || dialector.Client == nil {
+		return nil, errors.New("InfluxDB客户端未初始化")
+	}
+	return dialector, nil
+}
+
+// parseTable 解析模型或表名，模型会同时解析出 schema
+func (m Migrator) parseTable(value interface{}) (*gorm.Statement, error) {
+	stmt := &gorm.Statement{DB: m.DB}
+	if v, ok := value.(string); ok {
+		stmt.Table = v
+		return stmt, nil
+	}
+	if err := stmt.Parse(value); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// CreateLastCache 为模型创建 Last Value Cache，键列默认取模型的 tag 字段
+func (m Migrator) CreateLastCache(value interface{}, opts LastCacheOptions) error {
+	dialector, err := m.influxDialector()
+	if err != nil {
+		return err
+	}
+	stmt, err := m.parseTable(value)
+	if err != nil {
+		return err
+	}
+
+	keyColumns := opts.KeyColumns
+	if len(keyColumns) == 0 && stmt.Schema != nil {
+		keyColumns = tagColumns(stmt.Schema)
+	}
+
+	body := struct {
+		DB           string   `json:"db"`
+		Table        string   `json:"table"`
+		Name         string   `json:"name,omitempty"`
+		KeyColumns   []string `json:"key_columns,omitempty"`
+		ValueColumns []string `json:"value_columns,omitempty"`
+		Count        int      `json:"count,omitempty"`
+		TTL          int64    `json:"ttl,omitempty"`
+	}{
+		DB:           dialector.database(),
+		Table:        stmt.Table,
+		Name:         opts.Name,
+		KeyColumns:   keyColumns,
+		ValueColumns: opts.ValueColumns,
+		Count:        opts.Count,
+		TTL:          int64(opts.TTL / time.Second),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return dialector.callAPI(ctx, http.MethodPost, "/api/v3/configure/last_cache", nil, body)
+}
+
+// ListLastCaches 列出模型对应表上的所有 Last Value Cache
+func (m Migrator) ListLastCaches(value interface{}) ([]LastCache, error) {
+	dialector, err := m.influxDialector()
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := m.parseTable(value)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`SELECT "table", name, array_to_string(key_column_names, ',') AS key_columns, `+
+		`array_to_string(value_column_names, ',') AS value_columns, count, ttl `+
+		`FROM system.last_caches WHERE "table" = '%s'`, strings.ReplaceAll(stmt.Table, "'", "''"))
+	iterator, err := dialector.Client.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var caches []LastCache
+	for iterator.Next() {
+		row := iterator.Value()
+		caches = append(caches, LastCache{
+			Table:        fmt.Sprint(row["table"]),
+			Name:         fmt.Sprint(row["name"]),
+			KeyColumns:   splitColumns(row["key_columns"]),
+			ValueColumns: splitColumns(row["value_columns"]),
+			Count:        toInt64(row["count"]),
+			TTL:          time.Duration(toInt64(row["ttl"])) * time.Second,
+		})
+	}
+	return caches, iterator.Err()
+}
+
+// DropLastCache 删除模型对应表上指定名称的 Last Value Cache
+func (m Migrator) DropLastCache(value interface{}, name string) error {
+	dialector, err := m.influxDialector()
+	if err != nil {
+		return err
+	}
+	stmt, err := m.parseTable(value)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	params := url.Values{}
+	params.Set("db", dialector.database())
+	params.Set("table", stmt.Table)
+	params.Set("name", name)
+	return dialector.callAPI(ctx, http.MethodDelete, "/api/v3/configure/last_cache", params, nil)
+}
+
+// CreateDistinctCache 为模型创建 Distinct Value Cache，缓存列默认取模型的 tag 字段
+func (m Migrator) CreateDistinctCache(value interface{}, opts DistinctCacheOptions) error {
+	dialector, err := m.influxDialector()
+	if err != nil {
+		return err
+	}
+	stmt, err := m.parseTable(value)
+	if err != nil {
+		return err
+	}
+
+	columns := opts.Columns
+	if len(columns) == 0 && stmt.Schema != nil {
+		columns = tagColumns(stmt.Schema)
+	}
+	if len(columns) == 0 {
+		return errors.New("Distinct Value Cache 至少需要一个列")
+	}
+
+	body := struct {
+		DB             string   `json:"db"`
+		Table          string   `json:"table"`
+		Name           string   `json:"name,omitempty"`
+		Columns        []string `json:"columns"`
+		MaxCardinality int      `json:"max_cardinality,omitempty"`
+		MaxAge         int64    `json:"max_age,omitempty"`
+	}{
+		DB:             dialector.database(),
+		Table:          stmt.Table,
+		Name:           opts.Name,
+		Columns:        columns,
+		MaxCardinality: opts.MaxCardinality,
+		MaxAge:         int64(opts.MaxAge / time.Second),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return dialector.callAPI(ctx, http.MethodPost, "/api/v3/configure/distinct_cache", nil, body)
+}
+
+// DropDistinctCache 删除模型对应表上指定名称的 Distinct Value Cache
+func (m Migrator) DropDistinctCache(value interface{}, name string) error {
+	dialector, err := m.influxDialector()
+	if err != nil {
+		return err
+	}
+	stmt, err := m.parseTable(value)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	params := url.Values{}
+	params.Set("db", dialector.database())
+	params.Set("table", stmt.Table)
+	params.Set("name", name)
+	return dialector.callAPI(ctx, http.MethodDelete, "/api/v3/configure/distinct_cache", params, nil)
+}
+
+// LastValues 返回一个 GORM scope，将查询改为从 last_cache() 读取
+//
+//	db.Scopes(dialector.LastValues("cache_name")).Find(&actions)
+func LastValues(name string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db.Statement.TableExpr = &clause.Expr{SQL: "?", Vars: []interface{}{tableFunc{Func: "last_cache", Cache: name}}}
+		return db
+	}
+}
+
+// DistinctValues 返回一个 GORM scope，将查询改为从 distinct_cache() 读取
+func DistinctValues(name string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db.Statement.TableExpr = &clause.Expr{SQL: "?", Vars: []interface{}{tableFunc{Func: "distinct_cache", Cache: name}}}
+		return db
+	}
+}
+
+// DistinctTagValues 查询模型某一列的所有不同取值
+// 表上存在包含该列的 Distinct Value Cache 时通过 distinct_cache() 读取，否则回退为 SELECT DISTINCT
+func DistinctTagValues(db *gorm.DB, model interface{}, column string) ([]string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	if field := stmt.Schema.LookUpField(column); field != nil {
+		column = field.DBName
+	}
+
+	tx := db.Session(&gorm.Session{NewDB: true}).Model(model)
+	if name := distinctCacheFor(db, stmt.Table, column); name != "" {
+		tx = tx.Scopes(DistinctValues(name))
+	}
+
+	var values []string
+	err := tx.Distinct(column).Where(clause.Expr{SQL: "? IS NOT NULL", Vars: []interface{}{clause.Column{Name: column}}}).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: column}}).
+		Pluck(column, &values).Error
+	return values, err
+}
+
+// distinctCacheFor 查找表上包含指定列的 Distinct Value Cache 名称，不存在时返回空字符串
+func distinctCacheFor(db *gorm.DB, table, column string) string {
+	if db.DryRun {
+		return ""
+	}
+
+	var names []string
+	err := db.Session(&gorm.Session{NewDB: true}).
+		Raw(`SELECT name FROM system.distinct_caches WHERE "table" = ? AND array_has(column_names, ?)`, table, column).
+		Scan(&names).Error
+	// 查询系统表失败（例如服务端不支持 Distinct Value Cache）时按无缓存处理
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+// tableFunc 以表函数替代 FROM 子句中的表名，并以原表名作为别名，
+// 使 GORM 生成的 "table"."column" 形式的列引用仍然有效
+type tableFunc struct {
+	Func  string
+	Cache string
+}
+
+// Build 实现 clause.Expression 接口
+func (f tableFunc) Build(builder clause.Builder) {
+	stmt, ok := builder.(*gorm.Statement)
+	if !ok {
+		return
+	}
+	if stmt.Table == "" {
+		stmt.AddError(errors.New("表函数需要指定表名或模型"))
+		return
+	}
+
+	builder.WriteString(f.Func)
+	builder.WriteByte('(')
+	builder.AddVar(builder, stmt.Table)
+	if f.Cache != "" {
+		builder.WriteString(", ")
+		builder.AddVar(builder, f.Cache)
+	}
+	builder.WriteString(") AS ")
+	builder.WriteQuoted(clause.Table{Name: stmt.Table})
+}
+
+// splitColumns 将逗号分隔的列名拆分为切片
+func splitColumns(v interface{}) []string {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// toInt64 将查询结果中的整数值统一转换为 int64
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case uint64:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case uint32:
+		return int64(n)
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
@@ -0,0 +1,40 @@
+package dialector
+
+import (
+	"gorm.io/gorm"
+)
+
+// RegisterCallbacks 注册 InfluxDB3 专用的回调
+func (dialector *Dialector) RegisterCallbacks(db *gorm.DB) error {
+	// 查询前根据会话设置选择 SQL 或 InfluxQL
+	if err := db.Callback().Query().Before("gorm:query").Register("influxdb3:query_type", dialector.setQueryType); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register("influxdb3:query_type", dialector.setQueryType); err != nil {
+		return err
+	}
+	if err := db.Callback().Raw().Before("gorm:raw").Register("influxdb3:query_type", dialector.setQueryType); err != nil {
+		return err
+	}
+
+	// GORM 的 Row 回调直接设置 db.Error，不经过 AddError，需要单独转换错误
+	if err := db.Callback().Row().After("gorm:row").Register("influxdb3:translate_error", dialector.translateRowError); err != nil {
+		return err
+	}
+
+	// 查询时将模型未声明的列收集到动态 tag 和 field 中
+	if err := db.Callback().Query().Replace("gorm:query", dialector.queryCallback); err != nil {
+		return err
+	}
+
+	// InfluxDB 使用行协议写入，创建和更新转换为写入数据点
+	if err := db.Callback().Create().Replace("gorm:create", dialector.createCallback); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Replace("gorm:update", dialector.updateCallback); err != nil {
+		return err
+	}
+
+	// InfluxDB 不支持 DELETE 语句，删除转换为删除表或按条件删除
+	return db.Callback().Delete().Replace("gorm:delete", dialector.deleteCallback)
+}
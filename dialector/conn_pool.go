@@ -13,6 +13,7 @@ var _ gorm.ConnPool = &InfluxDBConnPool{}
 // InfluxDBConnPool 实现 gorm.ConnPool 接口
 type InfluxDBConnPool struct {
 	client *influxdb3.Client
+	config *Config
 }
 
 // PrepareContext 实现 gorm.ConnPool 接口
@@ -25,13 +26,14 @@ func (p *InfluxDBConnPool) PrepareContext(ctx context.Context, query string) (*s
 // ExecContext 实现 gorm.ConnPool 接口
 func (p *InfluxDBConnPool) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
 	// 转换查询和参数
-	influxQuery, err := translateQuery(query, args...)
+	queryType := queryTypeOf(ctx, p.config)
+	influxQuery, err := translateQuery(p.config, queryType, query, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	// 执行查询
-	_, err = p.client.Query(ctx, influxQuery)
+	_, err = p.client.Query(ctx, influxQuery, influxdb3.WithQueryType(queryType))
 	if err != nil {
 		return nil, err
 	}
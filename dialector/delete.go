@@ -0,0 +1,252 @@
+package dialector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// DeletePredicateError 删除条件无法转换为服务端支持的删除操作
+type DeletePredicateError struct {
+	Condition string // 无法转换的条件
+	Reason    string // 原因
+	Err       error  // 服务端返回的错误
+}
+
+func (e *DeletePredicateError) Error() string {
+	if e.Condition == "" {
+		return fmt.Sprintf("不支持的删除操作: %s", e.Reason)
+	}
+	return fmt.Sprintf("不支持的删除条件 %q: %s", e.Condition, e.Reason)
+}
+
+func (e *DeletePredicateError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	// InfluxDB 纳秒时间戳能够表示的最小和最大时间
+	minTimestamp = time.Unix(0, -1<<63+2).UTC()
+	maxTimestamp = time.Unix(0, 1<<63-2).UTC()
+
+	// deleteTermRegexp 匹配 `column op ?` 形式的简单比较条件
+	deleteTermRegexp = regexp.MustCompile(`^\s*(?:"?\w+"?\.)?"?(\w+)"?\s*(=|>=|<=|>|<)\s*\?\s*$`)
+	// andRegexp 按 AND 拆分条件
+	andRegexp = regexp.MustCompile(`(?i)\s+AND\s+`)
+)
+
+// deletePredicate 由 WHERE 条件转换得到的删除范围
+type deletePredicate struct {
+	start, stop time.Time
+	tags        []string // tag="value" 形式的等值条件
+}
+
+// deleteCallback 替换 GORM 的删除回调
+// 没有条件时删除整个表，否则将时间范围和 tag 等值条件转换为按条件删除
+func (dialector *Dialector) deleteCallback(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+
+	stmt := db.Statement
+	var exprs []clause.Expression
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok {
+			exprs = where.Exprs
+		}
+	}
+
+	if len(exprs) == 0 {
+		if !db.AllowGlobalUpdate {
+			db.AddError(gorm.ErrMissingWhereClause)
+			return
+		}
+		if stmt.Table == "" {
+			db.AddError(gorm.ErrModelValueRequired)
+			return
+		}
+		if db.DryRun {
+			return
+		}
+
+		params := url.Values{}
+		params.Set("db", dialector.database())
+		params.Set("table", stmt.Table)
+		db.AddError(dialector.callAPI(stmt.Context, http.MethodDelete, "/api/v3/configure/table", params, nil))
+		return
+	}
+
+	pred := &deletePredicate{start: minTimestamp, stop: maxTimestamp}
+	if err := pred.add(stmt, exprs); err != nil {
+		db.AddError(err)
+		return
+	}
+	if db.DryRun {
+		return
+	}
+
+	if err := dialector.deleteWithPredicate(stmt.Context, stmt.Table, pred); err != nil {
+		db.AddError(err)
+	}
+}
+
+// deleteWithPredicate 调用 v2 兼容的删除接口按条件删除数据
+func (dialector *Dialector) deleteWithPredicate(ctx context.Context, table string, pred *deletePredicate) error {
+	predicate := fmt.Sprintf(`_measurement="%s"`, escapePredicateValue(table))
+	for _, tag := range pred.tags {
+		predicate += " AND " + tag
+	}
+
+	params := url.Values{}
+	params.Set("bucket", dialector.database())
+	body := map[string]string{
+		"start":     pred.start.Format(time.RFC3339Nano),
+		"stop":      pred.stop.Format(time.RFC3339Nano),
+		"predicate": predicate,
+	}
+
+	err := dialector.callAPI(ctx, http.MethodPost, "/api/v2/delete", params, body)
+	var serverErr *influxdb3.ServerError
+	if errors.As(err, &serverErr) {
+		switch serverErr.StatusCode {
+		case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+			return &DeletePredicateError{Reason: "服务端不支持按条件删除，只能删除整个表", Err: err}
+		}
+	}
+	return err
+}
+
+// add 将条件合并到删除范围中，只支持 AND 连接的时间范围和 tag 等值条件
+func (p *deletePredicate) add(stmt *gorm.Statement, exprs []clause.Expression) error {
+	for _, expr := range exprs {
+		switch e := expr.(type) {
+		case clause.Eq:
+			if err := p.term(stmt, columnNameOf(e.Column), "=", e.Value); err != nil {
+				return err
+			}
+		case clause.Gt:
+			if err := p.term(stmt, columnNameOf(e.Column), ">", e.Value); err != nil {
+				return err
+			}
+		case clause.Gte:
+			if err := p.term(stmt, columnNameOf(e.Column), ">=", e.Value); err != nil {
+				return err
+			}
+		case clause.Lt:
+			if err := p.term(stmt, columnNameOf(e.Column), "<", e.Value); err != nil {
+				return err
+			}
+		case clause.Lte:
+			if err := p.term(stmt, columnNameOf(e.Column), "<=", e.Value); err != nil {
+				return err
+			}
+		case clause.AndConditions:
+			if err := p.add(stmt, e.Exprs); err != nil {
+				return err
+			}
+		case clause.Expr:
+			if err := p.addSQL(stmt, e); err != nil {
+				return err
+			}
+		default:
+			return &DeletePredicateError{Condition: fmt.Sprintf("%T", expr), Reason: "只支持 AND 连接的时间范围和 tag 等值条件"}
+		}
+	}
+	return nil
+}
+
+// addSQL 解析 Where("time < ? AND region = ?", ...) 形式的条件
+func (p *deletePredicate) addSQL(stmt *gorm.Statement, expr clause.Expr) error {
+	terms := andRegexp.Split(strings.TrimSpace(expr.SQL), -1)
+	if len(terms) != len(expr.Vars) {
+		return &DeletePredicateError{Condition: expr.SQL, Reason: "每个条件必须是 `列 运算符 ?` 的形式"}
+	}
+
+	for i, term := range terms {
+		match := deleteTermRegexp.FindStringSubmatch(term)
+		if match == nil {
+			return &DeletePredicateError{Condition: term, Reason: "每个条件必须是 `列 运算符 ?` 的形式"}
+		}
+		if err := p.term(stmt, match[1], match[2], expr.Vars[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// term 合并一个比较条件，时间戳列收窄时间范围，tag 列只支持等值比较
+// 时间戳列为 time 列或模型中 influx:time 标记的字段
+func (p *deletePredicate) term(stmt *gorm.Statement, column, op string, value interface{}) error {
+	condition := fmt.Sprintf("%s %s %v", column, op, value)
+
+	var field *schema.Field
+	if stmt.Schema != nil {
+		field = stmt.Schema.LookUpField(column)
+	}
+	if column == timeColumn.Name || (field != nil && isTimestampField(field)) {
+		t, ok := value.(time.Time)
+		if !ok {
+			if pt, isPtr := value.(*time.Time); isPtr && pt != nil {
+				t, ok = *pt, true
+			}
+		}
+		if !ok {
+			return &DeletePredicateError{Condition: condition, Reason: "时间戳列只能与 time.Time 比较"}
+		}
+
+		// 服务端的删除范围包含 start 和 stop
+		t = t.UTC()
+		switch op {
+		case ">":
+			t = t.Add(time.Nanosecond)
+			fallthrough
+		case ">=":
+			if t.After(p.start) {
+				p.start = t
+			}
+		case "<":
+			t = t.Add(-time.Nanosecond)
+			fallthrough
+		case "<=":
+			if t.Before(p.stop) {
+				p.stop = t
+			}
+		case "=":
+			if t.After(p.start) {
+				p.start = t
+			}
+			if t.Before(p.stop) {
+				p.stop = t
+			}
+		}
+		return nil
+	}
+
+	if op != "=" {
+		return &DeletePredicateError{Condition: condition, Reason: "tag 列只支持等值条件"}
+	}
+	if field != nil {
+		if !isTagField(field) {
+			return &DeletePredicateError{Condition: condition, Reason: "只能按 tag 列删除，不支持 field 列"}
+		}
+		column = field.DBName
+	}
+
+	p.tags = append(p.tags, fmt.Sprintf(`%s="%s"`, column, escapePredicateValue(fmt.Sprint(value))))
+	return nil
+}
+
+// escapePredicateValue 转义删除条件中的字符串值
+func escapePredicateValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v)
+}
@@ -5,10 +5,13 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"github.com/influxdata/line-protocol/v2/lineprotocol"
 	"gorm.io/gorm"
 	"gorm.io/gorm/callbacks"
 	"gorm.io/gorm/clause"
@@ -21,6 +24,7 @@ import (
 type InfluxDBStmt struct {
 	query  string
 	client *influxdb3.Client
+	config *Config
 }
 
 func (s *InfluxDBStmt) Close() error {
@@ -32,14 +36,20 @@ func (s *InfluxDBStmt) NumInput() int {
 }
 
 func (s *InfluxDBStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+// ExecContext 实现 driver.StmtExecContext 接口，查询语言从 context 中读取
+func (s *InfluxDBStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
 	// 转换查询和参数
-	influxQuery, err := translateQuery(s.query, argsToInterfaces(args)...)
+	queryType := queryTypeOf(ctx, s.config)
+	influxQuery, err := translateQuery(s.config, queryType, s.query, namedValuesToInterfaces(args)...)
 	if err != nil {
 		return nil, err
 	}
 
 	// 执行查询
-	_, err = s.client.Query(context.Background(), influxQuery)
+	_, err = s.client.Query(ctx, influxQuery, influxdb3.WithQueryType(queryType))
 	if err != nil {
 		return nil, err
 	}
@@ -48,30 +58,45 @@ func (s *InfluxDBStmt) Exec(args []driver.Value) (driver.Result, error) {
 }
 
 func (s *InfluxDBStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+// QueryContext 实现 driver.StmtQueryContext 接口，查询语言从 context 中读取
+func (s *InfluxDBStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
 	// 转换查询和参数
-	influxQuery, err := translateQuery(s.query, argsToInterfaces(args)...)
+	queryType := queryTypeOf(ctx, s.config)
+	influxQuery, err := translateQuery(s.config, queryType, s.query, namedValuesToInterfaces(args)...)
 	if err != nil {
 		return nil, err
 	}
 
-	// 执行查询
-	iterator, err := s.client.Query(context.Background(), influxQuery)
+	// 执行查询，数据流在结果集关闭时取消
+	ctx, cancel := context.WithCancel(ctx)
+	iterator, err := s.client.Query(ctx, influxQuery, influxdb3.WithQueryType(queryType))
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
 	// 将 InfluxDB 查询结果转换为 driver.Rows
-	rows := wrapRows(&InfluxDBRows{
-		Iterator: iterator,
-	})
+	rows := wrapRows(newInfluxDBRows(iterator, s.config.location(), cancel))
 	return rows, nil
 }
 
-// 辅助函数：将 driver.Value 数组转换为 interface{} 数组
-func argsToInterfaces(args []driver.Value) []interface{} {
-	result := make([]interface{}, len(args))
+// namedValues 将 driver.Value 数组转换为按位置编号的 driver.NamedValue 数组
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
 	for i, v := range args {
-		result[i] = v
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+// namedValuesToInterfaces 取出 driver.NamedValue 数组中的参数值
+func namedValuesToInterfaces(args []driver.NamedValue) []interface{} {
+	result := make([]interface{}, len(args))
+	for i, arg := range args {
+		result[i] = arg.Value
 	}
 	return result
 }
@@ -103,11 +128,32 @@ type Config struct {
 	DefaultBinarySize         uint // Default size for binary fields
 	SkipInitializeWithVersion bool // Skip smart configure based on detected version
 	DefaultDatetimePrecision  *int // Default datetime precision
+
+	// WritePrecision 写入时间戳的精度，模型可通过 WritePrecisioner 单独指定
+	WritePrecision lineprotocol.Precision
+
+	// Location 查询结果中的时间戳转换到的时区，为空时保持服务端返回的 UTC
+	Location *time.Location
+
+	// OmitZeroFields 写入时忽略零值的非指针字段，与 nil 指针一样不写入，
+	// 查询时 NULL 读取为零值，开启后 Save 不会把缺失的 field 写为零值
+	OmitZeroFields bool
+
+	// QueryType 默认的查询语言，可通过 db.Set(QueryTypeKey, "influxql") 按会话覆盖
+	QueryType influxdb3.QueryType
+
+	// AcceptPartial 部分数据点被服务端拒绝时保留已写入的数据点，不返回错误，
+	// 被拒绝的行通过日志和 OnPartialWrite 报告
+	AcceptPartial bool
+	// OnPartialWrite 开启 AcceptPartial 时接收被拒绝的行
+	OnPartialWrite func(ctx context.Context, err *PartialWriteError)
 }
 
 // Dialector InfluxDB3 dialector
 type Dialector struct {
 	*Config
+
+	createBatchSize int // 由驱动完成分批写入时的批次大小
 }
 
 // Name 返回数据库方言的名称
@@ -117,7 +163,7 @@ func (dialector *Dialector) Name() string {
 
 // Open 打开数据库连接
 func Open(dsn string) gorm.Dialector {
-	// 解析DSN格式: "host=xxx token=xxx database=xxx"
+	// 解析DSN格式: "host=xxx token=xxx database=xxx [query_type=sql|influxql] [accept_partial=true] [precision=ns|us|ms|s] [loc=Local|Asia/Shanghai] [omit_zero=true]"
 	configs := make(map[string]string)
 	for _, v := range strings.Split(dsn, " ") {
 		if parts := strings.SplitN(v, "=", 2); len(parts) == 2 {
@@ -132,6 +178,21 @@ func Open(dsn string) gorm.Dialector {
 			Database: configs["database"],
 		},
 	}
+	if queryType, ok := parseQueryType(configs["query_type"]); ok {
+		d.QueryType = queryType
+	}
+	if acceptPartial, err := strconv.ParseBool(configs["accept_partial"]); err == nil {
+		d.AcceptPartial = acceptPartial
+	}
+	if precision, ok := parsePrecision(configs["precision"]); ok {
+		d.WritePrecision = precision
+	}
+	if omitZero, err := strconv.ParseBool(configs["omit_zero"]); err == nil {
+		d.OmitZeroFields = omitZero
+	}
+	if loc, err := time.LoadLocation(configs["loc"]); err == nil && configs["loc"] != "" {
+		d.Location = loc
+	}
 	return d
 }
 
@@ -181,6 +242,7 @@ func (dialector *Dialector) Initialize(db *gorm.DB) (err error) {
 		// 创建连接池
 		connPool := &InfluxDBConnPool{
 			client: client,
+			config: dialector.Config,
 		}
 
 		// 验证连接
@@ -200,43 +262,64 @@ func (dialector *Dialector) Initialize(db *gorm.DB) (err error) {
 		db.ConnPool = connPool
 	}
 
+	// InfluxDB 不支持事务，跳过默认事务，否则分批创建会因无法开启事务而失败
+	db.SkipDefaultTransaction = true
+
+	// 分批写入由驱动完成，部分写入失败时才能对应到切片中的下标；
+	// 会话中的 CreateBatchSize 由 GORM 在执行回调前分批，驱动无法得知批次的偏移
+	if db.CreateBatchSize > 0 {
+		dialector.createBatchSize = db.CreateBatchSize
+		db.CreateBatchSize = 0
+	}
+
 	// 初始化回调
 	callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{})
 
 	// 注册自定义子句构造器
-	//db.ClauseBuilders["LIMIT"] = dialector.buildLimitClause
+	db.ClauseBuilders["LIMIT"] = dialector.buildLimitClause
+	db.ClauseBuilders["SELECT"] = dialector.buildSelectClause
+	db.ClauseBuilders["WHERE"] = dialector.buildWhereClause
+	db.ClauseBuilders["ORDER BY"] = dialector.buildOrderByClause
 
 	// 注册自定义回调
-	// 注：暂不需要实现自定义回调函数
-	// dialector.RegisterCallbacks(db)
-
-	return nil
+	return dialector.RegisterCallbacks(db)
 }
 
-// 转换查询和参数
-func translateQuery(query string, args ...any) (string, error) {
+// 转换查询和参数，queryType 决定时间参数的字面量格式
+func translateQuery(config *Config, queryType influxdb3.QueryType, query string, args ...any) (string, error) {
 	// 如果查询为空，返回错误
 	if query == "" {
-		return "", errors.New("查询语句为空")
+		return "", fmt.Errorf("%w: 查询语句为空", ErrInvalidQuery)
+	}
+
+	// 反引号标识符在绑定参数前转换为双引号，参数中的反引号保持不变
+	if strings.HasPrefix(strings.ToUpper(query), "SELECT") {
+		query = strings.ReplaceAll(query, "`", "\"")
 	}
 
 	// 替换参数占位符
-	for _, arg := range args {
+	query = bindVars(query, args, func(arg any) string {
 		switch v := arg.(type) {
 		case string:
-			// 字符串需要加引号
-			query = strings.Replace(query, "?", fmt.Sprintf("'%s'", strings.ReplaceAll(v, "'", "''")), 1)
+			// 字符串需要加引号，InfluxQL 使用反斜杠转义
+			if queryType == influxdb3.InfluxQL {
+				return influxQLStringLiteral(v)
+			}
+			return "'" + strings.ReplaceAll(v, "'", "''") + "'"
 		case time.Time:
-			// 时间格式化为 RFC3339
-			query = strings.Replace(query, "?", fmt.Sprintf("'%s'", v.Format(time.RFC3339)), 1)
+			// 时间按配置的精度格式化为 UTC 的 TIMESTAMP 字面量，InfluxQL 使用 RFC3339 字符串
+			if queryType == influxdb3.InfluxQL {
+				return influxQLTimeLiteral(v, config.datetimePrecision())
+			}
+			return timestampLiteral(v, config.datetimePrecision())
 		case nil:
 			// NULL 值
-			query = strings.Replace(query, "?", "NULL", 1)
+			return "NULL"
 		default:
 			// 其他类型直接转换为字符串
-			query = strings.Replace(query, "?", fmt.Sprintf("%v", v), 1)
+			return fmt.Sprintf("%v", v)
 		}
-	}
+	})
 
 	// 处理 SELECT 语句
 	if strings.HasPrefix(strings.ToUpper(query), "SELECT") {
@@ -250,27 +333,54 @@ func translateQuery(query string, args ...any) (string, error) {
 		// InfluxDB 3.0 支持 SQL 语法，可能不需要转换
 		// 但这里我们可以添加一些优化或特殊处理
 
-		// 处理表名中的引号
-		query = strings.Replace(query, "`", "\"", -1)
-
 		return query, nil
 	} else if strings.HasPrefix(strings.ToUpper(query), "INSERT") {
 		// 处理 INSERT 语句
 		// InfluxDB 使用行协议而不是 INSERT 语句
 		// 实际实现需要将 INSERT 转换为行协议
-		return "", errors.New("不支持 INSERT 语句，请使用 GORM 的 Create 方法")
+		return "", fmt.Errorf("%w: INSERT，请使用 GORM 的 Create 方法", ErrUnsupportedStatement)
 	} else if strings.HasPrefix(strings.ToUpper(query), "UPDATE") {
 		// 处理 UPDATE 语句
-		return "", errors.New("不支持 UPDATE 语句，请使用 GORM 的 Update 方法")
+		return "", fmt.Errorf("%w: UPDATE，请使用 GORM 的 Update 方法", ErrUnsupportedStatement)
 	} else if strings.HasPrefix(strings.ToUpper(query), "DELETE") {
 		// 处理 DELETE 语句
-		return "", errors.New("不支持 DELETE 语句，请使用 GORM 的 Delete 方法")
+		return "", fmt.Errorf("%w: DELETE，请使用 GORM 的 Delete 方法", ErrUnsupportedStatement)
 	}
 
 	// 对于其他类型的查询，直接返回
 	return query, nil
 }
 
+// bindVars 从左到右替换 ? 占位符，每个占位符消耗一个参数，已替换的内容不会再被扫描。
+// 单引号字符串和双引号标识符中的 ? 原样保留，参数不足时剩余的占位符保持不变
+func bindVars(query string, args []any, literal func(any) string) string {
+	if len(args) == 0 {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query))
+	var quote byte
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			// 转义的 '' 和 "" 相当于结束后立即重新开始
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?' && n < len(args):
+			b.WriteString(literal(args[n]))
+			n++
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
 // Migrator 返回迁移工具
 func (dialector *Dialector) Migrator(db *gorm.DB) gorm.Migrator {
 	return Migrator{migrator.Migrator{Config: migrator.Config{
@@ -285,8 +395,10 @@ func (dialector Dialector) DataTypeOf(field *schema.Field) string {
 	switch field.DataType {
 	case schema.Bool:
 		return "BOOLEAN"
-	case schema.Int, schema.Uint:
+	case schema.Int:
 		return "INTEGER"
+	case schema.Uint:
+		return "UINTEGER"
 	case schema.Float:
 		return "DOUBLE"
 	case schema.String:
@@ -318,22 +430,114 @@ func (dialector Dialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement,
 	writer.WriteByte('?')
 }
 
-// QuoteTo 添加引号
+// QuoteTo 按 SQL 标准为标识符添加双引号
+// 双引号之外的点号分隔限定名，iox.cpu 写为 "iox"."cpu"；标识符中的双引号转义为两个双引号。
+// 已经用双引号括起的部分原样写入，名称本身包含点号时可以写为 "cpu.usage" 或使用 Ident
 func (dialector Dialector) QuoteTo(writer clause.Writer, str string) {
+	for i, part := range splitIdentifier(str) {
+		if i > 0 {
+			writer.WriteByte('.')
+		}
+		switch {
+		case part == "*", isQuotedIdentifier(part):
+			writer.WriteString(part)
+		default:
+			writeIdentifier(writer, part)
+		}
+	}
+}
+
+// Ident 返回由 names 依次限定的标识符，每个名称都作为一个完整的标识符，不按点号拆分
+//
+//	db.Select("?", dialector.Ident("usage.idle")).Find(&rows) // SELECT "usage.idle" FROM ...
+func Ident(names ...string) clause.Expr {
+	var builder strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			builder.WriteByte('.')
+		}
+		writeIdentifier(&builder, name)
+	}
+	return clause.Expr{SQL: builder.String()}
+}
+
+// writeIdentifier 为单个标识符添加双引号并转义其中的双引号
+func writeIdentifier(writer clause.Writer, name string) {
 	writer.WriteByte('"')
-	writer.WriteString(str)
+	writer.WriteString(strings.ReplaceAll(name, `"`, `""`))
 	writer.WriteByte('"')
 }
 
-// Explain 解析SQL
+// splitIdentifier 按双引号之外的点号拆分限定名
+func splitIdentifier(str string) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i := 0; i < len(str); i++ {
+		switch str[i] {
+		case '"':
+			quoted = !quoted
+		case '.':
+			if !quoted {
+				parts = append(parts, str[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, str[start:])
+}
+
+// isQuotedIdentifier 判断是否为已经正确转义的双引号标识符
+func isQuotedIdentifier(part string) bool {
+	if len(part) < 2 || part[0] != '"' || part[len(part)-1] != '"' {
+		return false
+	}
+	return !strings.Contains(strings.ReplaceAll(part[1:len(part)-1], `""`, ""), `"`)
+}
+
+// Explain 按发送给服务端时的方式替换参数，用于日志和 db.ToSQL
+// 无法转换的语句按 GORM 默认的方式替换参数
 func (dialector Dialector) Explain(sql string, vars ...interface{}) string {
-	return logger.ExplainSQL(sql, nil, `"`, vars...)
+	if dialector.Config != nil {
+		if query, err := explainQuery(dialector.Config, dialector.QueryType, sql, vars...); err == nil {
+			return query
+		}
+	}
+	return logger.ExplainSQL(sql, nil, `'`, vars...)
+}
+
+// explainQuery 与 database/sql 一致地处理 driver.Valuer 和指针参数后替换参数
+func explainQuery(config *Config, queryType influxdb3.QueryType, sql string, vars ...interface{}) (string, error) {
+	values := make([]interface{}, len(vars))
+	for i, v := range vars {
+		value, err := convertParameter(v)
+		if err != nil {
+			return "", err
+		}
+		values[i] = value
+	}
+	return translateQuery(config, queryType, sql, values...)
+}
+
+// convertParameter 与 driver.DefaultParameterConverter 一致地转换参数，
+// 但无符号整数保留为 uint64，大于 math.MaxInt64 的值不会报错
+func convertParameter(v interface{}) (driver.Value, error) {
+	if _, ok := v.(driver.Valuer); !ok {
+		rv := reflect.ValueOf(v)
+		for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+			rv = rv.Elem()
+		}
+		switch rv.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return rv.Uint(), nil
+		}
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
 }
 
-// 构建LIMIT子句
+// 构建LIMIT子句，与 GORM 一致，负数的 LIMIT 表示不限制
 func (dialector *Dialector) buildLimitClause(c clause.Clause, builder clause.Builder) {
 	if limit, ok := c.Expression.(clause.Limit); ok {
-		if limit.Limit != nil {
+		if limit.Limit != nil && *limit.Limit >= 0 {
 			builder.WriteString(fmt.Sprintf("LIMIT %d", *limit.Limit))
 			if limit.Offset > 0 {
 				builder.WriteString(fmt.Sprintf(" OFFSET %d", limit.Offset))
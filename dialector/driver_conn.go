@@ -4,11 +4,17 @@ import (
 	"context"
 	"database/sql/driver"
 	"fmt"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
 )
 
 var (
-	_ driver.Driver    = &InfluxDBDriver{}
-	_ driver.Connector = &driverConnector{}
+	_ driver.Driver            = &InfluxDBDriver{}
+	_ driver.Connector         = &driverConnector{}
+	_ driver.QueryerContext    = &driverConn{}
+	_ driver.NamedValueChecker = &driverConn{}
+	_ driver.StmtQueryContext  = &InfluxDBStmt{}
+	_ driver.StmtExecContext   = &InfluxDBStmt{}
 )
 
 // InfluxDBDriver 实现 driver.Driver 接口
@@ -37,7 +43,7 @@ type driverConn struct {
 }
 
 func (c *driverConn) Prepare(query string) (driver.Stmt, error) {
-	return &InfluxDBStmt{query: query, client: c.pool.client}, nil
+	return &InfluxDBStmt{query: query, client: c.pool.client, config: c.pool.config}, nil
 }
 
 func (c *driverConn) Close() error {
@@ -48,39 +54,40 @@ func (c *driverConn) Begin() (driver.Tx, error) {
 	return nil, fmt.Errorf("transactions not supported")
 }
 
+// CheckNamedValue 实现 driver.NamedValueChecker 接口，保留 uint64 参数
+func (c *driverConn) CheckNamedValue(nv *driver.NamedValue) error {
+	value, err := convertParameter(nv.Value)
+	if err != nil {
+		return err
+	}
+	nv.Value = value
+	return nil
+}
+
 // Query 实现 driver.Queryer 接口
 func (c *driverConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	return c.QueryContext(context.Background(), query, namedValues(args))
+}
+
+// QueryContext 实现 driver.QueryerContext 接口，查询语言从 context 中读取
+func (c *driverConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
 	// 转换查询和参数
-	influxQuery, err := translateQuery(query, argsToInterfaces(args)...)
+	queryType := queryTypeOf(ctx, c.pool.config)
+	influxQuery, err := translateQuery(c.pool.config, queryType, query, namedValuesToInterfaces(args)...)
 	if err != nil {
 		return nil, err
 	}
 
-	// 执行查询
-	iterator, err := c.pool.client.Query(context.Background(), influxQuery)
+	// 执行查询，数据流在结果集关闭时取消
+	ctx, cancel := context.WithCancel(ctx)
+	iterator, err := c.pool.client.Query(ctx, influxQuery, influxdb3.WithQueryType(queryType))
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
 	// 创建自定义的 InfluxDBRows
-	influxRows := &InfluxDBRows{
-		Iterator: iterator,
-		columns:  []string{},
-	}
-
-	// 预先获取第一行数据以填充列信息
-	if iterator.Next() {
-		influxRows.skipNext = true
-		// 获取第一行数据
-		rowData := iterator.Value()
-		if rowData != nil {
-			// 提取列名
-			influxRows.columns = make([]string, 0, len(rowData))
-			for key := range rowData {
-				influxRows.columns = append(influxRows.columns, key)
-			}
-		}
-	}
+	influxRows := newInfluxDBRows(iterator, c.pool.config.location(), cancel)
 
 	// 返回包装后的行对象
 	return wrapRows(influxRows), nil
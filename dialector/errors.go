@@ -0,0 +1,139 @@
+package dialector
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// 验证 Dialector 是否实现了 gorm.ErrorTranslator 接口
+var _ gorm.ErrorTranslator = &Dialector{}
+
+var (
+	// ErrUnsupportedStatement InfluxDB 不支持的语句，如 INSERT、UPDATE、DELETE
+	ErrUnsupportedStatement = errors.New("不支持的语句")
+	// ErrInvalidQuery 查询语句有误，无法被服务端解析或执行
+	ErrInvalidQuery = errors.New("无效的查询")
+	// ErrTableNotFound 查询或操作的表不存在
+	ErrTableNotFound = errors.New("表不存在")
+	// ErrDatabaseNotFound 数据库不存在
+	ErrDatabaseNotFound = errors.New("数据库不存在")
+	// ErrUnauthorized 认证失败或没有权限
+	ErrUnauthorized = errors.New("认证失败或没有权限")
+	// ErrValueOutOfRange 整数超出了目标字段类型的范围，如负数写入无符号整数字段
+	ErrValueOutOfRange = errors.New("数值超出范围")
+)
+
+// WriteLineError 写入时被服务端拒绝的一行数据
+// 会话中设置的 CreateBatchSize 和 CreateInBatches 在进入驱动前由 GORM 切分切片，
+// 这时 Line 和 Index 按 GORM 的批次计算，而不是按调用方传入的整个切片
+type WriteLineError struct {
+	Line    int    // 行号，从 1 开始，按本次写入的全部数据点计算
+	Index   int    // 对应的模型在切片中的下标，无法对应时为 -1
+	Text    string // 原始的行协议
+	Message string // 服务端返回的错误信息
+}
+
+// PartialWriteError 部分数据点被服务端拒绝
+// 开启 AcceptPartial 时其余数据点已经写入，否则被拒绝的行所在批次的数据点都不会写入
+type PartialWriteError struct {
+	Lines []WriteLineError // 被拒绝的行
+	Err   error            // 服务端返回的错误
+}
+
+func (e *PartialWriteError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "部分数据写入失败，%d 行被拒绝", len(e.Lines))
+	for i, line := range e.Lines {
+		if i == 3 {
+			fmt.Fprintf(&sb, "; ...")
+			break
+		}
+		fmt.Fprintf(&sb, "; 第 %d 行: %s", line.Line, line.Message)
+	}
+	return sb.String()
+}
+
+func (e *PartialWriteError) Unwrap() error {
+	return e.Err
+}
+
+// Translate 实现 gorm.ErrorTranslator 接口，在 gorm.Config 中设置 TranslateError: true 后生效
+// gRPC 状态码和 HTTP 状态码被转换为本包定义的错误，原始错误仍可通过 errors.As 获取
+func (dialector *Dialector) Translate(err error) error {
+	return translateError(err)
+}
+
+// translateRowError 开启 TranslateError 时转换 Rows、Row 和 Scan 的查询错误
+func (dialector *Dialector) translateRowError(db *gorm.DB) {
+	if db.Error != nil && db.TranslateError {
+		db.Error = dialector.Translate(db.Error)
+	}
+}
+
+// translateError 将服务端错误转换为本包定义的错误
+func translateError(err error) error {
+	if err == nil || errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	for _, target := range []error{ErrUnsupportedStatement, ErrInvalidQuery, ErrTableNotFound, ErrDatabaseNotFound, ErrUnauthorized, ErrValueOutOfRange} {
+		if errors.Is(err, target) {
+			return err
+		}
+	}
+	// 已经由驱动解释过的服务端错误保持不变，例如删除接口的 404 表示不支持按条件删除，而不是表不存在
+	var partialErr *PartialWriteError
+	var predErr *DeletePredicateError
+	if errors.As(err, &partialErr) || errors.As(err, &predErr) {
+		return err
+	}
+
+	var serverErr *influxdb3.ServerError
+	if errors.As(err, &serverErr) {
+		switch serverErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %w", notFoundError(serverErr.Message), err)
+		case http.StatusBadRequest:
+			return fmt.Errorf("%w: %w", ErrInvalidQuery, err)
+		}
+		return err
+	}
+
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Unauthenticated, codes.PermissionDenied:
+			return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+		case codes.NotFound:
+			return fmt.Errorf("%w: %w", notFoundError(s.Message()), err)
+		case codes.InvalidArgument:
+			// DataFusion 规划查询时找不到表返回 InvalidArgument
+			if isTableNotFound(s.Message()) {
+				return fmt.Errorf("%w: %w", ErrTableNotFound, err)
+			}
+			return fmt.Errorf("%w: %w", ErrInvalidQuery, err)
+		}
+	}
+	return err
+}
+
+// notFoundError 根据错误信息区分数据库和表不存在
+func notFoundError(message string) error {
+	if strings.Contains(strings.ToLower(message), "database") {
+		return ErrDatabaseNotFound
+	}
+	return ErrTableNotFound
+}
+
+// isTableNotFound 判断错误信息是否表示表不存在
+func isTableNotFound(message string) bool {
+	message = strings.ToLower(message)
+	return strings.Contains(message, "not found") && (strings.Contains(message, "table") || strings.Contains(message, "measurement"))
+}
@@ -0,0 +1,262 @@
+package dialector
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// QueryTypeKey 会话级查询语言的设置键，取值为 "sql" 或 "influxql"
+//
+//	db.Set(dialector.QueryTypeKey, "influxql").Raw("SHOW MEASUREMENTS").Scan(&rows)
+const QueryTypeKey = "influxdb3:query_type"
+
+// queryTypeContextKey 在 context 中传递查询语言
+type queryTypeContextKey struct{}
+
+// withQueryType 将查询语言保存到 context 中
+func withQueryType(ctx context.Context, queryType influxdb3.QueryType) context.Context {
+	return context.WithValue(ctx, queryTypeContextKey{}, queryType)
+}
+
+// queryTypeOf 返回 context 中的查询语言，未设置时使用配置的默认值
+func queryTypeOf(ctx context.Context, config *Config) influxdb3.QueryType {
+	if ctx != nil {
+		if queryType, ok := ctx.Value(queryTypeContextKey{}).(influxdb3.QueryType); ok {
+			return queryType
+		}
+	}
+	if config != nil {
+		return config.QueryType
+	}
+	return influxdb3.SQL
+}
+
+// parseQueryType 解析查询语言设置，支持字符串和 influxdb3.QueryType
+func parseQueryType(v interface{}) (influxdb3.QueryType, bool) {
+	switch t := v.(type) {
+	case influxdb3.QueryType:
+		return t, true
+	case string:
+		switch strings.ToLower(t) {
+		case "influxql":
+			return influxdb3.InfluxQL, true
+		case "sql":
+			return influxdb3.SQL, true
+		}
+	}
+	return influxdb3.SQL, false
+}
+
+// statementQueryType 返回语句使用的查询语言，会话设置优先于配置
+func (dialector *Dialector) statementQueryType(stmt *gorm.Statement) influxdb3.QueryType {
+	if v, ok := stmt.Settings.Load(QueryTypeKey); ok {
+		if queryType, ok := parseQueryType(v); ok {
+			return queryType
+		}
+	}
+	return dialector.QueryType
+}
+
+// setQueryType 查询前将会话的查询语言写入 context，供连接池选择查询类型
+func (dialector *Dialector) setQueryType(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	if v, ok := db.Get(QueryTypeKey); ok {
+		queryType, ok := parseQueryType(v)
+		if !ok {
+			db.AddError(gorm.ErrInvalidValue)
+			return
+		}
+		db.Statement.Context = withQueryType(db.Statement.Context, queryType)
+	}
+}
+
+// buildWhereClause 构建 WHERE 子句，InfluxQL 模式下转换为 InfluxQL 支持的条件
+func (dialector *Dialector) buildWhereClause(c clause.Clause, builder clause.Builder) {
+	if stmt, ok := builder.(*gorm.Statement); ok && dialector.statementQueryType(stmt) == influxdb3.InfluxQL {
+		if where, ok := c.Expression.(clause.Where); ok {
+			exprs, err := influxQLExprs(where.Exprs)
+			if err != nil {
+				stmt.AddError(err)
+				return
+			}
+			where.Exprs = exprs
+			c.Expression = where
+		}
+	}
+	c.Build(builder)
+}
+
+// buildOrderByClause 构建 ORDER BY 子句，InfluxQL 模式下去掉列的表名限定
+// 模型没有主键时，First 和 Last 按时间戳排序，而不是按第一个字段排序
+func (dialector *Dialector) buildOrderByClause(c clause.Clause, builder clause.Builder) {
+	if stmt, ok := builder.(*gorm.Statement); ok {
+		if orderBy, ok := c.Expression.(clause.OrderBy); ok {
+			influxQL := dialector.statementQueryType(stmt) == influxdb3.InfluxQL
+			columns := make([]clause.OrderByColumn, len(orderBy.Columns))
+			for i, column := range orderBy.Columns {
+				column.Column = orderByPrimaryKey(stmt, column.Column)
+				if influxQL {
+					column.Column = influxQLColumn(column.Column)
+				}
+				columns[i] = column
+			}
+			orderBy.Columns = columns
+			c.Expression = orderBy
+		}
+	}
+	c.Build(builder)
+}
+
+// orderByPrimaryKey 将没有主键的模型上的主键列替换为时间戳列
+// influx:time 字段的列名只是查询结果中的别名，排序使用服务端的 time 列
+func orderByPrimaryKey(stmt *gorm.Statement, column clause.Column) clause.Column {
+	if column.Name != clause.PrimaryKey || column.Raw || stmt.Schema == nil || stmt.Schema.PrioritizedPrimaryField != nil {
+		return column
+	}
+	if field := timestampField(stmt.Schema); field != nil && field.DBName != "" {
+		column.Name = timeColumn.Name
+	}
+	return column
+}
+
+// influxQLColumns 去掉 SELECT 列的表名限定
+func influxQLColumns(columns []clause.Column) []clause.Column {
+	result := make([]clause.Column, len(columns))
+	for i, column := range columns {
+		result[i] = influxQLColumn(column)
+	}
+	return result
+}
+
+// influxQLColumn InfluxQL 不支持 "table"."column" 形式的列引用
+func influxQLColumn(column clause.Column) clause.Column {
+	column.Table = ""
+	return column
+}
+
+// influxQLExprs 将 GORM 生成的条件转换为 InfluxQL 支持的形式
+// 列去掉表名限定，IN 条件展开为 OR 连接的等值比较，无法转换的 IN 条件返回 ErrUnsupportedStatement
+func influxQLExprs(exprs []clause.Expression) ([]clause.Expression, error) {
+	result := make([]clause.Expression, len(exprs))
+	for i, expr := range exprs {
+		converted, err := influxQLExpr(expr)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = converted
+	}
+	return result, nil
+}
+
+func influxQLExpr(expr clause.Expression) (clause.Expression, error) {
+	column := func(v interface{}) interface{} {
+		if c, ok := v.(clause.Column); ok {
+			return influxQLColumn(c)
+		}
+		return v
+	}
+
+	var err error
+	switch e := expr.(type) {
+	case clause.Eq:
+		e.Column = column(e.Column)
+		return e, nil
+	case clause.Neq:
+		e.Column = column(e.Column)
+		return e, nil
+	case clause.Gt:
+		e.Column = column(e.Column)
+		return e, nil
+	case clause.Gte:
+		e.Column = column(e.Column)
+		return e, nil
+	case clause.Lt:
+		e.Column = column(e.Column)
+		return e, nil
+	case clause.Lte:
+		e.Column = column(e.Column)
+		return e, nil
+	case clause.Like:
+		e.Column = column(e.Column)
+		return e, nil
+	case clause.IN:
+		if len(e.Values) == 0 {
+			return e, nil
+		}
+		return influxQLIn(column(e.Column), e.Values, false), nil
+	case clause.Expr:
+		return influxQLInExpr(e)
+	case clause.AndConditions:
+		e.Exprs, err = influxQLExprs(e.Exprs)
+		return e, err
+	case clause.OrConditions:
+		e.Exprs, err = influxQLExprs(e.Exprs)
+		return e, err
+	case clause.NotConditions:
+		e.Exprs, err = influxQLExprs(e.Exprs)
+		return e, err
+	}
+	return expr, nil
+}
+
+// influxQLIn 将 IN 展开为 OR 连接的等值比较，NOT IN 展开为 AND 连接的不等比较
+func influxQLIn(column interface{}, values []interface{}, not bool) clause.Expression {
+	exprs := make([]clause.Expression, len(values))
+	for i, value := range values {
+		if not {
+			exprs[i] = clause.Neq{Column: column, Value: value}
+		} else {
+			exprs[i] = clause.Eq{Column: column, Value: value}
+		}
+	}
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+	if not {
+		return clause.AndConditions{Exprs: exprs}
+	}
+	return clause.AndConditions{Exprs: []clause.Expression{clause.OrConditions{Exprs: exprs}}}
+}
+
+var (
+	// inPlaceholder 匹配去掉标识符引号后 Where("column IN ?", values) 形式的条件
+	inPlaceholder = regexp.MustCompile(`(?i)^\s*(?:\w+\.)?(\w+)\s+(NOT\s+)?IN\s*(?:\?|\(\s*\?\s*\))\s*$`)
+	// inOperator 匹配条件中的 IN 运算符
+	inOperator = regexp.MustCompile(`(?i)\bIN\s*[(?]`)
+	// quotedString 匹配单引号字符串，检查 IN 运算符前先去掉
+	quotedString = regexp.MustCompile(`'(?:[^']|'')*'`)
+)
+
+// influxQLInExpr 转换 SQL 片段形式的 IN 条件，InfluxQL 不支持 IN
+func influxQLInExpr(e clause.Expr) (clause.Expression, error) {
+	if !inOperator.MatchString(quotedString.ReplaceAllString(e.SQL, "")) {
+		return e, nil
+	}
+	unquoted := strings.NewReplacer(`"`, "", "`", "").Replace(e.SQL)
+	if m := inPlaceholder.FindStringSubmatch(unquoted); m != nil && len(e.Vars) == 1 {
+		rv := reflect.ValueOf(e.Vars[0])
+		if (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8 && rv.Len() > 0 {
+			values := make([]interface{}, rv.Len())
+			for i := range values {
+				values[i] = rv.Index(i).Interface()
+			}
+			return influxQLIn(clause.Column{Name: m[1]}, values, m[2] != ""), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: InfluxQL 不支持 IN 条件 %q，只支持 column IN ? 形式的切片参数", ErrUnsupportedStatement, e.SQL)
+}
+
+// influxQLStringLiteral 将字符串转换为 InfluxQL 的字符串字面量，反斜杠和单引号使用反斜杠转义
+func influxQLStringLiteral(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
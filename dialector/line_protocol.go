@@ -0,0 +1,186 @@
+package dialector
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"github.com/influxdata/line-protocol/v2/lineprotocol"
+)
+
+var (
+	// measurementEscaper 转义 measurement 中的逗号和空格
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	// keyEscaper 转义 tag 键、tag 值和 field 键中的逗号、等号和空格
+	keyEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	// stringEscaper 转义字符串 field 值中的反斜杠和双引号
+	stringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// EncodePoints 将数据点编码为行协议，时间戳按纳秒精度写出
+//
+// tag 和 field 按键排序；有符号整数带 i 后缀，无符号整数带 u 后缀，字符串加双引号，
+// 布尔值写为 true/false。浮点数的 NaN 和 Inf、空的键、包含控制字符或以反斜杠结尾的名称都会返回错误
+func EncodePoints(points []*influxdb3.Point) ([]byte, error) {
+	return encodePoints(points, lineprotocol.Nanosecond)
+}
+
+// encodePoints 按指定精度将数据点编码为行协议，每个数据点一行
+func encodePoints(points []*influxdb3.Point, precision lineprotocol.Precision) ([]byte, error) {
+	var buf []byte
+	for i, point := range points {
+		var err error
+		if buf, err = appendPoint(buf, point, precision); err != nil {
+			return nil, fmt.Errorf("编码第 %d 个数据点: %w", i, err)
+		}
+	}
+	return buf, nil
+}
+
+// appendPoint 将一个数据点编码为一行行协议追加到 buf
+func appendPoint(buf []byte, point *influxdb3.Point, precision lineprotocol.Precision) ([]byte, error) {
+	if point == nil || point.Values == nil {
+		return nil, fmt.Errorf("数据点为空")
+	}
+	values := point.Values
+
+	if err := checkName("measurement", values.MeasurementName); err != nil {
+		return nil, err
+	}
+	buf = append(buf, measurementEscaper.Replace(values.MeasurementName)...)
+
+	tagKeys := make([]string, 0, len(values.Tags))
+	for key := range values.Tags {
+		tagKeys = append(tagKeys, key)
+	}
+	sort.Strings(tagKeys)
+	for _, key := range tagKeys {
+		value := values.Tags[key]
+		if err := checkName("tag 键", key); err != nil {
+			return nil, err
+		}
+		if err := checkName("tag "+key+" 的值", value); err != nil {
+			return nil, err
+		}
+		buf = append(buf, ',')
+		buf = append(buf, keyEscaper.Replace(key)...)
+		buf = append(buf, '=')
+		buf = append(buf, keyEscaper.Replace(value)...)
+	}
+
+	if len(values.Fields) == 0 {
+		return nil, fmt.Errorf("数据点 %s 至少需要一个 field", values.MeasurementName)
+	}
+	fieldKeys := make([]string, 0, len(values.Fields))
+	for key := range values.Fields {
+		fieldKeys = append(fieldKeys, key)
+	}
+	sort.Strings(fieldKeys)
+	for i, key := range fieldKeys {
+		if err := checkName("field 键", key); err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			buf = append(buf, ' ')
+		} else {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, keyEscaper.Replace(key)...)
+		buf = append(buf, '=')
+
+		var err error
+		if buf, err = appendFieldValue(buf, values.Fields[key]); err != nil {
+			return nil, fmt.Errorf("field %s: %w", key, err)
+		}
+	}
+
+	if !values.Timestamp.IsZero() {
+		ts, err := timestampValue(values.Timestamp, precision)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, ' ')
+		buf = strconv.AppendInt(buf, ts, 10)
+	}
+	return append(buf, '\n'), nil
+}
+
+// appendFieldValue 按类型编码 field 值
+func appendFieldValue(buf []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		value = string(v)
+	case time.Time:
+		value = v.Format(time.RFC3339Nano)
+	case time.Duration:
+		value = v.String()
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return append(strconv.AppendInt(buf, rv.Int(), 10), 'i'), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return append(strconv.AppendUint(buf, rv.Uint(), 10), 'u'), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("不支持的浮点数 %v", f)
+		}
+		bitSize := 64
+		if rv.Kind() == reflect.Float32 {
+			bitSize = 32
+		}
+		return strconv.AppendFloat(buf, f, 'g', -1, bitSize), nil
+	case reflect.Bool:
+		return strconv.AppendBool(buf, rv.Bool()), nil
+	case reflect.String:
+		if !utf8.ValidString(rv.String()) {
+			return nil, fmt.Errorf("字符串不是有效的 UTF-8")
+		}
+		buf = append(buf, '"')
+		buf = append(buf, stringEscaper.Replace(rv.String())...)
+		return append(buf, '"'), nil
+	}
+	return nil, fmt.Errorf("不支持的 field 类型 %T", value)
+}
+
+// timestampValue 将时间戳转换为指定精度的整数，超出纳秒时间戳范围时返回错误
+// 1970 年之前的时间戳向下取整，与服务端按精度截断的结果一致，例如 -1.5s 按秒写入为 -2
+func timestampValue(t time.Time, precision lineprotocol.Precision) (int64, error) {
+	if t.Before(minTimestamp) || t.After(maxTimestamp) {
+		return 0, fmt.Errorf("时间戳 %s 超出范围", t.Format(time.RFC3339Nano))
+	}
+	ns, unit := t.UnixNano(), int64(precision.Duration())
+	v := ns / unit
+	if ns%unit < 0 {
+		v--
+	}
+	return v, nil
+}
+
+// checkName 检查 measurement、tag 和 field 的名称
+// 名称不能为空，不能包含控制字符，不能以反斜杠结尾，否则会转义后面的分隔符
+func checkName(kind, name string) error {
+	if name == "" {
+		return fmt.Errorf("%s 不能为空", kind)
+	}
+	if !utf8.ValidString(name) {
+		return fmt.Errorf("%s %q 不是有效的 UTF-8", kind, name)
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f {
+			return fmt.Errorf("%s %q 包含控制字符", kind, name)
+		}
+	}
+	if strings.HasSuffix(name, `\`) {
+		return fmt.Errorf("%s %q 不能以反斜杠结尾", kind, name)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package dialector
+
+import (
+	"bytes"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"github.com/influxdata/line-protocol/v2/lineprotocol"
+	"gorm.io/gorm"
+)
+
+// writePreviewKey 在 Statement.Settings 中保存写入预览的键
+const writePreviewKey = "influxdb3:write_preview"
+
+// WritePreview DryRun 模式下 Create、Update、Save 生成但没有发送的写入内容
+type WritePreview struct {
+	Table        string                 // 写入的表
+	Precision    lineprotocol.Precision // 时间戳精度
+	Points       []*influxdb3.Point     // 将要写入的数据点
+	LineProtocol string                 // 按 Precision 编码的行协议，每个数据点一行
+	SQL          string                 // 带条件的更新在重写前执行的查询语句，参数已替换
+}
+
+// Preview 返回 DryRun 会话中写入操作生成的预览，没有写入操作时返回 false
+//
+//	tx := db.Session(&gorm.Session{DryRun: true}).Create(&rows)
+//	preview, _ := dialector.Preview(tx)
+//	fmt.Print(preview.LineProtocol)
+func Preview(db *gorm.DB) (*WritePreview, bool) {
+	if db == nil || db.Statement == nil {
+		return nil, false
+	}
+	v, ok := db.Statement.Settings.Load(writePreviewKey)
+	if !ok {
+		return nil, false
+	}
+	preview, ok := v.(*WritePreview)
+	return preview, ok
+}
+
+// previewPoints 编码数据点但不发送，行协议同时写入 Statement.SQL，
+// 使 db.ToSQL 和日志输出与查询一样可以看到将要写入的内容
+func previewPoints(db *gorm.DB, points []*influxdb3.Point, precision lineprotocol.Precision) {
+	stmt := db.Statement
+	data, err := encodePoints(points, precision)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+
+	stmt.SQL.Reset()
+	stmt.SQL.Write(bytes.TrimSuffix(data, []byte("\n")))
+	stmt.Settings.Store(writePreviewKey, &WritePreview{
+		Table:        stmt.Table,
+		Precision:    precision,
+		Points:       points,
+		LineProtocol: string(data),
+	})
+}
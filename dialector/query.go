@@ -0,0 +1,217 @@
+package dialector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/schema"
+)
+
+// queryCallback 替换 GORM 的查询回调
+// 扫描到模型时由 scanDynamic 逐列赋值：模型未声明的列被收集到 `influx:tags` 或 `influx:fields` 标记的 map 中，
+// NULL 列将字段重置为零值，时间戳经 GORM 的字段赋值转换为整数。其他情况与 GORM 的默认实现一致
+func (dialector *Dialector) queryCallback(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || !scansModel(stmt) {
+		callbacks.Query(db)
+		return
+	}
+
+	callbacks.BuildQuerySQL(db)
+	if db.DryRun || db.Error != nil {
+		return
+	}
+
+	rows, err := stmt.ConnPool.QueryContext(stmt.Context, stmt.SQL.String(), stmt.Vars...)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	defer func() {
+		db.AddError(rows.Close())
+	}()
+
+	db.AddError(scanDynamic(db, rows))
+	if db.RowsAffected == 0 && stmt.RaiseErrorOnNotFound && db.Error == nil {
+		db.AddError(gorm.ErrRecordNotFound)
+	}
+	if stmt.Result != nil {
+		stmt.Result.RowsAffected = db.RowsAffected
+	}
+}
+
+// scansModel 判断查询结果是否扫描到模型中
+func scansModel(stmt *gorm.Statement) bool {
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		elem := stmt.ReflectValue.Type().Elem()
+		for elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		return elem == stmt.Schema.ModelType
+	case reflect.Struct:
+		return stmt.ReflectValue.Type() == stmt.Schema.ModelType
+	}
+	return false
+}
+
+// scanDynamic 将结果扫描到模型，未声明的 tag 列放入 `influx:tags`，其余列放入 `influx:fields`
+func scanDynamic(db *gorm.DB, rows *sql.Rows) error {
+	stmt := db.Statement
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+
+	tagsField, fieldsField := dynamicField(stmt.Schema, "tags"), dynamicField(stmt.Schema, "fields")
+	tsField := timestampField(stmt.Schema)
+	fields := make([]*schema.Field, len(columns))
+	dynamic := make([]*schema.Field, len(columns))
+	for i, column := range columns {
+		field := stmt.Schema.LookUpField(column)
+		if field == nil && column == timeColumn.Name {
+			field = tsField
+		}
+		switch {
+		case field != nil && field.Readable && !isDynamicField(field):
+			fields[i] = field
+		case field != nil:
+		case columnTypes[i].DatabaseTypeName() == "TAG":
+			dynamic[i] = tagsField
+		default:
+			dynamic[i] = fieldsField
+		}
+	}
+
+	reflectValue := stmt.ReflectValue
+	isSlice := reflectValue.Kind() == reflect.Slice || reflectValue.Kind() == reflect.Array
+	if isSlice && reflectValue.Kind() == reflect.Slice {
+		reflectValue.Set(reflect.MakeSlice(reflectValue.Type(), 0, 20))
+	}
+
+	values := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if !isSlice && db.RowsAffected > 0 {
+			break
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+
+		elem := reflect.New(stmt.Schema.ModelType).Elem()
+		if !isSlice {
+			elem = reflectValue
+		}
+		for i, value := range values {
+			if fields[i] != nil {
+				if err := setFieldValue(stmt.Context, fields[i], elem, columns[i], value); err != nil {
+					return err
+				}
+			} else if dynamic[i] != nil && value != nil {
+				if err := setMapValue(dynamic[i].ReflectValueOf(stmt.Context, elem), columns[i], value); err != nil {
+					return err
+				}
+			}
+		}
+
+		if isSlice {
+			if reflectValue.Type().Elem().Kind() == reflect.Ptr {
+				elem = elem.Addr()
+			}
+			if reflectValue.Kind() == reflect.Slice {
+				reflectValue.Set(reflect.Append(reflectValue, elem))
+			} else if int(db.RowsAffected) < reflectValue.Len() {
+				reflectValue.Index(int(db.RowsAffected)).Set(elem)
+			}
+		}
+		db.RowsAffected++
+	}
+	return rows.Err()
+}
+
+// setMapValue 将值写入动态 tag 或 field 的 map，map 为空时自动创建
+func setMapValue(m reflect.Value, key string, value interface{}) error {
+	if m.IsNil() {
+		m.Set(reflect.MakeMap(m.Type()))
+	}
+
+	v := reflect.ValueOf(value)
+	elemType := m.Type().Elem()
+	if overflows(elemType, value) {
+		return fmt.Errorf("%w: 列 %s 的值 %v 无法放入 %s", ErrValueOutOfRange, key, value, m.Type())
+	}
+	switch {
+	case v.Type().AssignableTo(elemType):
+	case elemType.Kind() == reflect.String:
+		v = reflect.ValueOf(fmt.Sprint(value)).Convert(elemType)
+	case v.Type().ConvertibleTo(elemType):
+		v = v.Convert(elemType)
+	default:
+		return fmt.Errorf("无法将列 %s 的值 %v 放入 %s", key, value, m.Type())
+	}
+	m.SetMapIndex(reflect.ValueOf(key).Convert(m.Type().Key()), v)
+	return nil
+}
+
+// setFieldValue 将查询结果写入模型字段，serializer 字段先经 serializer 解码
+// GORM 的 Set 会截断超出字段类型范围的整数，这里改为返回 ErrValueOutOfRange；
+// NULL 将字段重置为零值，指针为 nil，sql.Null* 的 Valid 为 false
+func setFieldValue(ctx context.Context, field *schema.Field, rv reflect.Value, column string, value interface{}) error {
+	if value == nil {
+		field.ReflectValueOf(ctx, rv).Set(reflect.Zero(field.FieldType))
+		return nil
+	}
+	if overflows(field.IndirectFieldType, value) {
+		return fmt.Errorf("%w: 列 %s 的值 %v 无法放入字段 %s (%s)", ErrValueOutOfRange, column, value, field.Name, field.IndirectFieldType)
+	}
+	if field.Serializer != nil {
+		// 与 GORM 扫描结果时相同，经 serializer 解码后再写入字段
+		s := field.NewValuePool.Get()
+		defer field.NewValuePool.Put(s)
+		if err := s.(sql.Scanner).Scan(value); err != nil {
+			return err
+		}
+		value = s
+	}
+	return field.Set(ctx, rv, value)
+}
+
+// overflows 判断整数值能否放入整数类型 t，其他类型不检查
+func overflows(t reflect.Type, value interface{}) bool {
+	rv := reflect.ValueOf(value)
+	if !rv.IsValid() {
+		return false
+	}
+	target := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return target.OverflowInt(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return rv.Uint() > math.MaxInt64 || target.OverflowInt(int64(rv.Uint()))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return rv.Int() < 0 || target.OverflowUint(uint64(rv.Int()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return target.OverflowUint(rv.Uint())
+		}
+	}
+	return false
+}
@@ -1,62 +1,68 @@
 package dialector
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"errors"
 	"fmt"
-	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
 	"io"
-	"strings"
 	"time"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"github.com/apache/arrow-go/v18/arrow"
 )
 
 // InfluxDBRows 实现结果集接口
+// 结果直接从 Arrow 记录批次中按列类型读取，不经过 QueryIterator 的逐行 map
 type InfluxDBRows struct {
 	Iterator *influxdb3.QueryIterator
-	columns  []string // 列名列表
-	skipNext bool     //true时不需要执行Next方法
+	columns  []string       // 列名列表
+	fields   []arrow.Field  // 列的 Arrow 类型，与 columns 一一对应
+	record   arrow.Record   // 当前的记录批次
+	row      int            // 当前行在记录批次中的位置
+	index    int64          // 当前行在整个结果中的位置
+	location *time.Location // 时间戳转换到的时区，为空时保持列类型中的时区
+	cancel   context.CancelFunc
+	closed   bool
 	err      error
 }
 
-func (r *InfluxDBRows) Index() int64 {
-	if r.Iterator == nil {
-		return 0
-	}
-
-	if index, ok := r.Iterator.Index().(int64); ok {
-		return index
+// newInfluxDBRows 按结果的 Arrow schema 确定列的顺序和类型，时间戳转换到 loc 时区
+// cancel 取消本次查询，关闭结果集时调用以结束未读完的 Flight 数据流
+func newInfluxDBRows(iterator *influxdb3.QueryIterator, loc *time.Location, cancel context.CancelFunc) *InfluxDBRows {
+	rows := &InfluxDBRows{Iterator: iterator, columns: []string{}, index: -1, location: loc, cancel: cancel}
+	if reader := iterator.Raw(); reader != nil && reader.Schema() != nil {
+		rows.fields = reader.Schema().Fields()
+		for _, field := range rows.fields {
+			rows.columns = append(rows.columns, field.Name)
+		}
 	}
+	return rows
+}
 
-	return 0
+func (r *InfluxDBRows) Index() int64 {
+	return r.index
 }
 
-// Next 移动到下一行
+// Next 移动到下一行，当前记录批次读完后读取下一个批次
 func (r *InfluxDBRows) Next() bool {
-	if r.Iterator == nil {
+	if r.Iterator == nil || r.Iterator.Raw() == nil {
 		r.err = errors.New("查询迭代器为空")
 		return false
 	}
 
-	// 如果skipNext为true，直接返回
-	if r.skipNext {
-		r.skipNext = false
-		return true
-	}
-
-	if !r.Iterator.Next() {
-		return false
-	}
-
-	rowData := r.Iterator.Value()
-	// 如果列为空，则更新列信息
-	if len(r.columns) == 0 {
-		r.columns = make([]string, 0, len(rowData))
-		for key := range rowData {
-			r.columns = append(r.columns, key)
+	reader := r.Iterator.Raw()
+	r.row++
+	for r.record == nil || r.row >= int(r.record.NumRows()) {
+		if !reader.Next() {
+			r.err = reader.Err()
+			return false
 		}
+		r.record = reader.Record()
+		r.row = 0
 	}
-
+	r.index++
 	return true
 }
 
@@ -65,8 +71,21 @@ func (r *InfluxDBRows) Err() error {
 	return r.err
 }
 
-// Close 关闭结果集
+// Close 关闭结果集，取消未读完的 Flight 数据流并释放读取器
 func (r *InfluxDBRows) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.record = nil
+	if r.cancel != nil {
+		r.cancel()
+	}
+	if r.Iterator != nil {
+		if reader := r.Iterator.Raw(); reader != nil {
+			reader.Release()
+		}
+	}
 	return nil
 }
 
@@ -88,16 +107,12 @@ func (r *InfluxDBRows) ColumnTypes() ([]*sql.ColumnType, error) {
 
 // 创建一个包装的 InfluxDBRows
 func wrapRows(rows *InfluxDBRows) driverRows {
-	return driverRows{
-		InfluxDBRows: rows,
-		currentRow:   make(map[string]any),
-	}
+	return driverRows{InfluxDBRows: rows}
 }
 
 // 实现 driver.Rows 接口的包装器
 type driverRows struct {
 	*InfluxDBRows
-	currentRow map[string]any
 }
 
 func (r driverRows) Columns() []string {
@@ -109,8 +124,9 @@ func (r driverRows) Close() error {
 	return r.InfluxDBRows.Close()
 }
 
+// Next 按 Arrow 列类型读取当前行：整数为 int64 或 uint64，浮点数为 float64，
+// 时间戳按列的单位和时区转换为 time.Time，database/sql 再按目标类型转换
 func (r driverRows) Next(dest []driver.Value) error {
-
 	if !r.InfluxDBRows.Next() {
 		if err := r.InfluxDBRows.Err(); err != nil {
 			return err
@@ -118,73 +134,30 @@ func (r driverRows) Next(dest []driver.Value) error {
 		return io.EOF
 	}
 
-	// 获取当前行的数据
-	cols, _ := r.InfluxDBRows.Columns()
-
-	// 检查是否有列存在，如果为空则跳过处理
-	if len(cols) == 0 {
-		return nil
-	}
-
-	// 如果没有目标变量但有列，这是一个特殊情况
-	// 在sql.Rows.Next()调用期间可能发生，此时只需返回成功
-	if len(dest) == 0 {
-		return nil
-	}
-
-	// 如果列数与目标变量数不匹配，使用可用的列数
-	colsToProcess := len(cols)
-	if len(dest) < colsToProcess {
-		colsToProcess = len(dest)
-	}
-
-	// 将当前行的数据复制到目标变量
-	for i := 0; i < colsToProcess; i++ {
-		col := cols[i]
-		val, ok := r.InfluxDBRows.Iterator.Value()[col]
-		if !ok {
+	record := r.record
+	for i := range dest {
+		if i >= int(record.NumCols()) {
 			dest[i] = nil
 			continue
 		}
-
-		// 根据不同的数据类型进行转换
-		switch v := val.(type) {
-		case string:
-			dest[i] = v
-		case int:
-			dest[i] = int64(v)
-		case int32:
-			dest[i] = int64(v)
-		case int64:
-			dest[i] = v
-		case float32:
-			dest[i] = float64(v)
-		case float64:
-			// 特殊处理：如果是COUNT函数的结果，转换为整数
-			if strings.Contains(strings.ToLower(col), "count(") || strings.Contains(strings.ToLower(col), "count") ||
-				col == "action_type" || col == "duration" {
-				dest[i] = int64(v)
-			} else {
-				dest[i] = v
-			}
-		case bool:
-			dest[i] = v
-		case time.Time:
-			dest[i] = v
-		case nil:
-			dest[i] = nil
-		default:
-			dest[i] = fmt.Sprintf("%v", v)
+		value, err := arrowValue(record.Column(i), r.row, r.location)
+		if err != nil {
+			return fmt.Errorf("读取列 %s 失败: %w", record.ColumnName(i), err)
 		}
+		dest[i] = value
 	}
-	// 将剩余的目标变量设置为nil
-	for i := colsToProcess; i < len(dest); i++ {
-		dest[i] = nil
-	}
-
 	return nil
 }
 
+// ColumnTypeDatabaseTypeName 实现 driver.RowsColumnTypeDatabaseTypeName 接口
+// tag 列返回 TAG，时间戳列返回 TIMESTAMP，其余返回 Arrow 类型名称
+func (r driverRows) ColumnTypeDatabaseTypeName(index int) string {
+	if index < 0 || index >= len(r.fields) {
+		return ""
+	}
+	return columnTypeName(r.fields[index])
+}
+
 // 实现 driver.Result 接口的包装器
 type driverResult struct {
 	*InfluxDBResult
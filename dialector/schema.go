@@ -0,0 +1,93 @@
+package dialector
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/influxdata/line-protocol/v2/lineprotocol"
+	"gorm.io/gorm/schema"
+)
+
+// WritePrecisioner 模型实现该接口时按返回的精度写入时间戳，优先于 Config.WritePrecision
+//
+//	func (Weather) WritePrecision() lineprotocol.Precision {
+//		return lineprotocol.Millisecond
+//	}
+type WritePrecisioner interface {
+	WritePrecision() lineprotocol.Precision
+}
+
+// isTagField 判断字段是否为 InfluxDB 的 tag，模型中使用 `gorm:"type:tag"` 标记
+func isTagField(field *schema.Field) bool {
+	return strings.EqualFold(field.TagSettings["TYPE"], "tag")
+}
+
+// tagColumns 按模型字段顺序返回所有 tag 列名
+func tagColumns(s *schema.Schema) []string {
+	var columns []string
+	for _, field := range s.Fields {
+		if field.DBName != "" && isTagField(field) {
+			columns = append(columns, field.DBName)
+		}
+	}
+	return columns
+}
+
+// timestampField 返回作为数据点时间戳的字段
+// 使用 `gorm:"influx:time"` 标记的字段优先，否则使用 time 列
+func timestampField(s *schema.Schema) *schema.Field {
+	for _, field := range s.Fields {
+		if strings.EqualFold(field.TagSettings["INFLUX"], "time") {
+			return field
+		}
+	}
+	return s.LookUpField(timeColumn.Name)
+}
+
+// isTimestampField 判断字段是否为数据点的时间戳
+func isTimestampField(field *schema.Field) bool {
+	return field.DBName == timeColumn.Name || field == timestampField(field.Schema)
+}
+
+// modelWritePrecision 返回模型通过 WritePrecisioner 指定的写入精度
+func modelWritePrecision(s *schema.Schema) (lineprotocol.Precision, bool) {
+	if p, ok := reflect.New(s.ModelType).Interface().(WritePrecisioner); ok {
+		return p.WritePrecision(), true
+	}
+	return lineprotocol.Nanosecond, false
+}
+
+// parsePrecision 解析 ns、us、ms、s 形式的精度
+func parsePrecision(v string) (lineprotocol.Precision, bool) {
+	switch strings.ToLower(v) {
+	case "ns", "nanosecond":
+		return lineprotocol.Nanosecond, true
+	case "us", "microsecond":
+		return lineprotocol.Microsecond, true
+	case "ms", "millisecond":
+		return lineprotocol.Millisecond, true
+	case "s", "second":
+		return lineprotocol.Second, true
+	}
+	return lineprotocol.Nanosecond, false
+}
+
+// dynamicField 返回使用 `gorm:"influx:tags"` 或 `gorm:"influx:fields"` 标记的 map 字段
+// GORM 无法解析 map 类型的列，这类字段通常同时带有 `-`，因此不检查字段的读写权限
+func dynamicField(s *schema.Schema, kind string) *schema.Field {
+	for _, field := range s.Fields {
+		if !strings.EqualFold(field.TagSettings["INFLUX"], kind) {
+			continue
+		}
+		if t := field.FieldType; t.Kind() == reflect.Map && t.Key().Kind() == reflect.String {
+			return field
+		}
+	}
+	return nil
+}
+
+// isDynamicField 判断字段是否为动态 tag 或 field 的 map
+func isDynamicField(field *schema.Field) bool {
+	kind := strings.ToLower(field.TagSettings["INFLUX"])
+	return (kind == "tags" || kind == "fields") && field.FieldType.Kind() == reflect.Map
+}
@@ -0,0 +1,190 @@
+package dialector
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// buildQuery 使用 DryRun 生成 GORM 链式调用对应的查询语句，并完成参数替换
+func buildQuery(db *gorm.DB, dest interface{}) (*Dialector, string, influxdb3.QueryType, error) {
+	dialector, ok := db.Dialector.(*Dialector)
+	if !ok || dialector.Client == nil {
+		return nil, "", influxdb3.SQL, errors.New("InfluxDB客户端未初始化")
+	}
+
+	tx := db.Session(&gorm.Session{DryRun: true}).Find(dest)
+	if tx.Error != nil {
+		return nil, "", influxdb3.SQL, tx.Error
+	}
+
+	queryType := dialector.statementQueryType(tx.Statement)
+	query, err := explainQuery(dialector.Config, queryType, tx.Statement.SQL.String(), tx.Statement.Vars...)
+	return dialector, query, queryType, err
+}
+
+// queryReader 执行 GORM 链式调用对应的查询并返回 Arrow Flight 读取器
+// 调用方在读取结束后需要调用 cancel 并释放读取器
+func queryReader(db *gorm.DB, dest interface{}) (*flight.Reader, context.CancelFunc, error) {
+	dialector, query, queryType, err := buildQuery(db, dest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+
+	iterator, err := dialector.Client.Query(ctx, query, influxdb3.WithQueryType(queryType))
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return iterator.Raw(), cancel, nil
+}
+
+// recordReader 在释放时同时取消 Flight 数据流
+type recordReader struct {
+	*flight.Reader
+	refs   atomic.Int64
+	cancel context.CancelFunc
+}
+
+// Retain 增加引用计数
+func (r *recordReader) Retain() {
+	r.refs.Add(1)
+	r.Reader.Retain()
+}
+
+// Release 减少引用计数，归零时取消查询
+func (r *recordReader) Release() {
+	r.Reader.Release()
+	if r.refs.Add(-1) == 0 {
+		r.cancel()
+	}
+}
+
+// QueryArrow 执行 GORM 链式调用对应的查询，直接返回 Arrow 记录批次，不做逐行转换
+// 每次 Next 之后 Record() 返回的记录在下一次 Next 前有效，需要保留时调用 Retain
+// 使用完毕后必须调用 Release，未读完的数据流会被取消
+//
+//	reader, err := dialector.QueryArrow(ctx, db.Model(&UserAction{}).Where("time > ?", start))
+//	if err != nil {
+//		return err
+//	}
+//	defer reader.Release()
+//	for reader.Next() {
+//		record := reader.Record()
+//		...
+//	}
+//	return reader.Err()
+func QueryArrow(ctx context.Context, db *gorm.DB) (array.RecordReader, error) {
+	reader, cancel, err := queryReader(db.WithContext(ctx), &[]map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	r := &recordReader{Reader: reader, cancel: cancel}
+	r.refs.Store(1)
+	return r, nil
+}
+
+// Stream 以迭代器的方式逐行读取查询结果，不会将全部结果加载到内存
+// 结果直接从 Arrow 记录批次解码，消费者提前结束迭代时会取消 Flight 数据流
+//
+//	for action, err := range dialector.Stream[UserAction](db.Where("time > ?", start)) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func Stream[T any](db *gorm.DB) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(&zero); err != nil {
+			yield(zero, err)
+			return
+		}
+
+		reader, cancel, err := queryReader(db, &[]T{})
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer cancel()
+		defer reader.Release()
+
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		tagsField, fieldsField := dynamicField(stmt.Schema, "tags"), dynamicField(stmt.Schema, "fields")
+		tsField := timestampField(stmt.Schema)
+		var loc *time.Location
+		if dialector, ok := db.Dialector.(*Dialector); ok {
+			loc = dialector.location()
+		}
+
+		for reader.Next() {
+			record := reader.Record()
+
+			// 按列名找到模型字段，未映射的列放入动态 tag 或 field，没有动态字段时忽略
+			fields := make([]*schema.Field, record.NumCols())
+			dynamic := make([]*schema.Field, record.NumCols())
+			for i, column := range record.Schema().Fields() {
+				field := stmt.Schema.LookUpField(column.Name)
+				if field == nil && column.Name == timeColumn.Name {
+					field = tsField
+				}
+				switch {
+				case field != nil && !isDynamicField(field):
+					fields[i] = field
+				case field != nil:
+				case isTagColumn(column):
+					dynamic[i] = tagsField
+				default:
+					dynamic[i] = fieldsField
+				}
+			}
+
+			for row := 0; row < int(record.NumRows()); row++ {
+				var item T
+				rv := reflect.ValueOf(&item).Elem()
+				for i, field := range fields {
+					if field == nil && dynamic[i] == nil {
+						continue
+					}
+					value, err := arrowValue(record.Column(i), row, loc)
+					if err == nil && field != nil {
+						err = setFieldValue(ctx, field, rv, record.ColumnName(i), value)
+					} else if err == nil && value != nil {
+						err = setMapValue(dynamic[i].ReflectValueOf(ctx, rv), record.ColumnName(i), value)
+					}
+					if err != nil {
+						yield(zero, err)
+						return
+					}
+				}
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+
+		if err := reader.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package dialector
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// timeBucketKey 保存在 Statement.Settings 中的时间分桶配置
+const timeBucketKey = "influxdb3:time_bucket"
+
+// ErrMissingTimeRange 间隙填充查询缺少时间范围条件
+var ErrMissingTimeRange = errors.New("间隙填充需要在 WHERE 中同时指定时间列的下界和上界")
+
+// TimeBucketOptions 时间分桶的可选配置
+type TimeBucketOptions struct {
+	Origin   time.Time      // 分桶起点，为零值时使用 Location 时区的 1970-01-01 00:00:00
+	Location *time.Location // 分桶对齐的时区，为空时使用 UTC；夏令时切换期间按固定偏移对齐
+	Alias    string         // 分桶列的别名，为空时使用原时间列名，便于扫描到模型的 time.Time 字段
+	GapFill  bool           // 使用 date_bin_gapfill 补齐没有数据的时间桶
+}
+
+// timeBucket 描述一次 date_bin 分桶
+type timeBucket struct {
+	Interval time.Duration
+	Column   string
+	Origin   time.Time
+	Alias    string
+	GapFill  bool
+}
+
+// TimeBucket 返回一个 GORM scope，按固定间隔对时间列分桶
+// SELECT 中会加入 date_bin(...) AS "time"，GROUP BY 中会加入相同的分桶表达式
+//
+//	db.Model(&Metric{}).Scopes(dialector.TimeBucket(5*time.Minute, "time")).
+//		Select("region, avg(value) AS value").Group("region").Find(&rows)
+func TimeBucket(interval time.Duration, column string, opts ...TimeBucketOptions) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if interval <= 0 {
+			db.AddError(fmt.Errorf("无效的分桶间隔: %s", interval))
+			return db
+		}
+
+		bucket := timeBucket{Interval: interval, Column: column, Alias: column}
+		loc := time.UTC
+		for _, opt := range opts {
+			if opt.Location != nil {
+				loc = opt.Location
+			}
+			if !opt.Origin.IsZero() {
+				bucket.Origin = opt.Origin
+			}
+			if opt.Alias != "" {
+				bucket.Alias = opt.Alias
+			}
+			bucket.GapFill = bucket.GapFill || opt.GapFill
+		}
+		if bucket.Origin.IsZero() {
+			bucket.Origin = time.Date(1970, 1, 1, 0, 0, 0, 0, loc)
+		}
+
+		db.Statement.Settings.Store(timeBucketKey, bucket)
+		db.Statement.AddClause(clause.GroupBy{Columns: []clause.Column{{Name: bucket.expr(db.Statement), Raw: true}}})
+		return db
+	}
+}
+
+// GapFill 返回一个 GORM scope，与 TimeBucket 相同但使用 date_bin_gapfill 补齐空的时间桶
+// 查询必须在 WHERE 中同时限定时间列的下界和上界，配合 Interpolate 或 LOCF 填充缺失值
+//
+//	db.Model(&Metric{}).Scopes(dialector.GapFill(5*time.Minute, "time")).
+//		Select("region, ? AS value", dialector.LOCF("avg(value)")).
+//		Where("time >= ? AND time < ?", start, end).Group("region").Find(&rows)
+func GapFill(interval time.Duration, column string, opts ...TimeBucketOptions) func(*gorm.DB) *gorm.DB {
+	return TimeBucket(interval, column, append(opts, TimeBucketOptions{GapFill: true})...)
+}
+
+// Interpolate 返回 interpolate(expr) 表达式，对缺失的时间桶做线性插值
+func Interpolate(expr string) clause.Expr {
+	return clause.Expr{SQL: "interpolate(" + expr + ")"}
+}
+
+// LOCF 返回 locf(expr) 表达式，使用上一个非空值填充缺失的时间桶
+func LOCF(expr string) clause.Expr {
+	return clause.Expr{SQL: "locf(" + expr + ")"}
+}
+
+// expr 返回 date_bin 表达式
+func (b timeBucket) expr(stmt *gorm.Statement) string {
+	fn := "date_bin"
+	if b.GapFill {
+		fn = "date_bin_gapfill"
+	}
+	return fmt.Sprintf("%s(%s, %s, %s)", fn, intervalLiteral(b.Interval), stmt.Quote(b.Column), timestampLiteral(b.Origin, 9))
+}
+
+// buildSelectClause 构建 SELECT 子句，存在时间分桶时将分桶表达式放在第一列
+func (dialector *Dialector) buildSelectClause(c clause.Clause, builder clause.Builder) {
+	stmt, ok := builder.(*gorm.Statement)
+	if !ok {
+		c.Build(builder)
+		return
+	}
+	if dialector.statementQueryType(stmt) == influxdb3.InfluxQL {
+		if sel, ok := c.Expression.(clause.Select); ok {
+			sel.Columns = influxQLColumns(sel.Columns)
+			c.Expression = sel
+		}
+	}
+	v, ok := stmt.Settings.Load(timeBucketKey)
+	if !ok {
+		if sel, ok := c.Expression.(clause.Select); ok && dialector.statementQueryType(stmt) == influxdb3.SQL {
+			c.Expression = timestampColumns(stmt, sel)
+		}
+		c.Build(builder)
+		return
+	}
+	bucket := v.(timeBucket)
+
+	// 服务端要求 date_bin_gapfill 的查询必须有完整的时间范围，提前返回明确的错误
+	if bucket.GapFill {
+		if lower, upper := timeRangeOf(stmt, bucket.Column); !lower || !upper {
+			stmt.AddError(ErrMissingTimeRange)
+			return
+		}
+	}
+
+	// DISTINCT 必须紧跟 SELECT，由这里写入后不再由 clause.Select 重复写入
+	sel, isSelect := c.Expression.(clause.Select)
+	builder.WriteString("SELECT ")
+	if isSelect && sel.Distinct {
+		builder.WriteString("DISTINCT ")
+		sel.Distinct = false
+		c.Expression = sel
+	}
+	builder.WriteString(bucket.expr(stmt))
+	builder.WriteString(" AS ")
+	builder.WriteQuoted(bucket.Alias)
+
+	switch sel := c.Expression.(type) {
+	case clause.Select:
+		// 去掉与分桶列同名的普通列，避免与分桶结果冲突
+		columns := make([]clause.Column, 0, len(sel.Columns))
+		for _, column := range sel.Columns {
+			if column.Raw || (column.Name != bucket.Column && column.Name != bucket.Alias) {
+				columns = append(columns, column)
+			}
+		}
+		if len(columns) > 0 {
+			builder.WriteString(", ")
+			sel.Columns = columns
+			sel.Build(builder)
+		}
+	case nil:
+	default:
+		builder.WriteString(", ")
+		sel.Build(builder)
+	}
+}
+
+// timestampColumns 时间戳字段的列名不是 time 时，将服务端的 time 列以该列名返回
+func timestampColumns(stmt *gorm.Statement, sel clause.Select) clause.Select {
+	if stmt.Schema == nil {
+		return sel
+	}
+	field := timestampField(stmt.Schema)
+	if field == nil || field.DBName == timeColumn.Name {
+		return sel
+	}
+
+	alias := clause.Column{Name: stmt.Quote(timeColumn.Name) + " AS " + stmt.Quote(field.DBName), Raw: true}
+	if len(sel.Columns) == 0 {
+		sel.Columns = []clause.Column{{Name: "*", Raw: true}, alias}
+		return sel
+	}
+	columns := make([]clause.Column, len(sel.Columns))
+	for i, column := range sel.Columns {
+		if !column.Raw && column.Name == field.DBName {
+			column = alias
+		}
+		columns[i] = column
+	}
+	sel.Columns = columns
+	return sel
+}
+
+// timeColumn 时间范围 scope 使用的时间列
+var timeColumn = clause.Column{Name: "time"}
+
+// Between 返回一个 GORM scope，限定 time 列位于 [start, end) 区间
+func Between(start, end time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Gte{Column: timeColumn, Value: start}).Where(clause.Lt{Column: timeColumn, Value: end})
+	}
+}
+
+// Since 返回一个 GORM scope，限定 time 列不早于服务端当前时间之前的 d
+// 与 Last 不同，不限定上界，时间戳晚于 now() 的数据点也会返回
+func Since(d time.Duration) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Gte{Column: timeColumn, Value: clause.Expr{SQL: "now() - " + intervalLiteral(d)}})
+	}
+}
+
+// Last 返回一个 GORM scope，限定 time 列位于服务端当前时间之前的 d 时间内
+func Last(d time.Duration) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Gte{Column: timeColumn, Value: clause.Expr{SQL: "now() - " + intervalLiteral(d)}}).
+			Where(clause.Lte{Column: timeColumn, Value: clause.Expr{SQL: "now()"}})
+	}
+}
+
+// timeRangeOf 检查 WHERE 子句中是否限定了时间列的下界和上界
+func timeRangeOf(stmt *gorm.Statement, column string) (lower, upper bool) {
+	c, ok := stmt.Clauses["WHERE"]
+	if !ok {
+		return false, false
+	}
+	where, ok := c.Expression.(clause.Where)
+	if !ok {
+		return false, false
+	}
+
+	pattern := regexp.MustCompile(`(?i)(?:^|[^\w"])"?` + regexp.QuoteMeta(column) + `"?\s*(>=|>|<=|<|BETWEEN\b)`)
+	var visit func(exprs []clause.Expression)
+	visit = func(exprs []clause.Expression) {
+		for _, expr := range exprs {
+			switch e := expr.(type) {
+			case clause.Gt:
+				lower = lower || columnNameOf(e.Column) == column
+			case clause.Gte:
+				lower = lower || columnNameOf(e.Column) == column
+			case clause.Lt:
+				upper = upper || columnNameOf(e.Column) == column
+			case clause.Lte:
+				upper = upper || columnNameOf(e.Column) == column
+			case clause.AndConditions:
+				visit(e.Exprs)
+			case clause.Where:
+				visit(e.Exprs)
+			case clause.Expr:
+				visitSQL(pattern, e.SQL, &lower, &upper)
+			case clause.NamedExpr:
+				visitSQL(pattern, e.SQL, &lower, &upper)
+			}
+		}
+	}
+	visit(where.Exprs)
+	return lower, upper
+}
+
+// visitSQL 在 SQL 片段中查找时间列的比较运算，OR 条件中的比较不能作为时间范围
+func visitSQL(pattern *regexp.Regexp, sql string, lower, upper *bool) {
+	if strings.Contains(strings.ToUpper(sql), " OR ") {
+		return
+	}
+	for _, match := range pattern.FindAllStringSubmatch(sql, -1) {
+		switch strings.ToUpper(match[1]) {
+		case ">", ">=":
+			*lower = true
+		case "<", "<=":
+			*upper = true
+		case "BETWEEN":
+			*lower, *upper = true, true
+		}
+	}
+}
+
+// columnNameOf 返回条件中的列名
+func columnNameOf(column interface{}) string {
+	switch c := column.(type) {
+	case clause.Column:
+		return c.Name
+	case string:
+		return c
+	}
+	return ""
+}
+
+// intervalLiteral 将时间间隔转换为 SQL INTERVAL 字面量
+func intervalLiteral(d time.Duration) string {
+	switch {
+	case d%time.Second == 0:
+		return fmt.Sprintf("INTERVAL '%d seconds'", d/time.Second)
+	case d%time.Millisecond == 0:
+		return fmt.Sprintf("INTERVAL '%d milliseconds'", d/time.Millisecond)
+	case d%time.Microsecond == 0:
+		return fmt.Sprintf("INTERVAL '%d microseconds'", d/time.Microsecond)
+	default:
+		return fmt.Sprintf("INTERVAL '%d nanoseconds'", d)
+	}
+}
+
+// timestampLiteral 将时间转换为 UTC 的 SQL TIMESTAMP 字面量，precision 为秒的小数位数
+func timestampLiteral(t time.Time, precision int) string {
+	return "TIMESTAMP '" + formatTimestamp(t, precision) + "'"
+}
+
+// influxQLTimeLiteral 将时间转换为 InfluxQL 的 RFC3339 时间字符串
+func influxQLTimeLiteral(t time.Time, precision int) string {
+	return "'" + formatTimestamp(t, precision) + "'"
+}
+
+// formatTimestamp 按指定的小数位数将时间格式化为 UTC 的 RFC3339 字符串
+func formatTimestamp(t time.Time, precision int) string {
+	layout := "2006-01-02T15:04:05"
+	if precision > 0 {
+		layout += "." + strings.Repeat("0", precision)
+	}
+	t = t.UTC().Truncate(time.Duration(math.Pow10(9 - precision)))
+	return t.Format(layout) + "Z"
+}
+
+// datetimePrecision 返回时间参数的小数位数
+// DefaultDatetimePrecision 优先，其次 DisableNanoTimestamps 时使用微秒，默认为纳秒
+func (config *Config) datetimePrecision() int {
+	if config == nil {
+		return 9
+	}
+	if p := config.DefaultDatetimePrecision; p != nil {
+		return min(max(*p, 0), 9)
+	}
+	if config.DisableNanoTimestamps {
+		return 6
+	}
+	return 9
+}
+
+// location 返回查询结果中时间戳转换到的时区
+func (config *Config) location() *time.Location {
+	if config == nil {
+		return nil
+	}
+	return config.Location
+}
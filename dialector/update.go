@@ -0,0 +1,386 @@
+package dialector
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrNoFieldsToUpdate 更新中没有任何需要写入的 field
+var ErrNoFieldsToUpdate = errors.New("没有需要更新的字段")
+
+// updateCallback 替换 GORM 的更新回调
+// InfluxDB 中 measurement、tag 和时间戳都相同的数据点会覆盖已有的 field，
+// 更新通过写入只包含变更字段的同一数据点实现。
+// 模型带有时间戳时按模型的 tag 和时间戳查询对应的数据点，有 WHERE 条件时查询匹配的数据点，
+// 再按查询结果中完整的 tag 和时间戳分批重写，模型没有声明的 tag 也会保留
+func (dialector *Dialector) updateCallback(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+
+	stmt := db.Statement
+	if stmt.Schema == nil {
+		db.AddError(gorm.ErrModelValueRequired)
+		return
+	}
+
+	changes, err := updateChanges(stmt, dialector.OmitZeroFields)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	if len(changes) == 0 {
+		db.AddError(ErrNoFieldsToUpdate)
+		return
+	}
+
+	var exprs []clause.Expression
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok {
+			exprs = where.Exprs
+		}
+	}
+	if len(exprs) > 0 {
+		dialector.updateWhere(db, exprs, changes)
+		return
+	}
+
+	// 没有条件时按模型中的 tag 和时间戳定位数据点
+	var points []*influxdb3.Point
+	var matches []clause.Expression
+	var models []reflect.Value
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			models = append(models, reflect.Indirect(stmt.ReflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		models = append(models, stmt.ReflectValue)
+	}
+	for _, rv := range models {
+		tags, ts, err := identityOf(stmt, rv)
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+		if ts.IsZero() {
+			points = nil
+			break
+		}
+		points = append(points, updatePoint(stmt.Table, tags, ts, changes))
+		matches = append(matches, identityCondition(stmt.Schema, tags, ts))
+	}
+
+	if len(points) == 0 {
+		if isSave(stmt) {
+			// Save 的模型没有时间戳，不存在可覆盖的数据点，RowsAffected 为 0 时 GORM 会改为创建。
+			// DryRun 时 GORM 不会改为创建，直接预览创建的数据点
+			if db.DryRun {
+				dialector.createCallback(db)
+			}
+			return
+		}
+		if !db.AllowGlobalUpdate {
+			db.AddError(gorm.ErrMissingWhereClause)
+			return
+		}
+		dialector.updateWhere(db, nil, changes)
+		return
+	}
+
+	// DryRun 时无法查询，按模型预览要写入的数据点
+	if db.DryRun {
+		previewPoints(db, points, dialector.writePrecision(stmt))
+		return
+	}
+
+	// 模型中的 tag 不一定是序列的全部 tag，直接写入可能产生新的序列。
+	// 先按模型的 tag 和时间戳查询数据点，再按查询结果中完整的 tag 重写
+	dialector.updateWhere(db, []clause.Expression{clause.Or(matches...)}, changes)
+	if db.Error != nil {
+		return
+	}
+
+	// 与 GORM 一致，将更新后的值写回模型
+	if !isSave(stmt) {
+		for _, rv := range models {
+			for column, value := range changes {
+				if field := stmt.Schema.LookUpField(column); field != nil {
+					if err := setFieldValue(stmt.Context, field, rv, column, value); err != nil {
+						db.AddError(err)
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// updateWhere 查询匹配条件的数据点，按原有的 tag 和时间戳分批重写变更的字段
+// 查询全部列，tag 由结果的列元数据确定，包括模型中没有声明的 tag，重写的数据点与原数据点属于同一序列
+func (dialector *Dialector) updateWhere(db *gorm.DB, exprs []clause.Expression, changes map[string]interface{}) {
+	stmt := db.Statement
+	tx := db.Session(&gorm.Session{NewDB: true, Context: stmt.Context}).Table(stmt.Table)
+	if v, ok := stmt.Settings.Load(QueryTypeKey); ok {
+		tx = tx.Set(QueryTypeKey, v)
+	}
+	if len(exprs) > 0 {
+		tx = tx.Clauses(clause.Where{Exprs: exprs})
+	}
+
+	// DryRun 时匹配的数据点未知，只预览查询语句
+	if db.DryRun {
+		dryTx := tx.Session(&gorm.Session{DryRun: true}).Find(&[]map[string]interface{}{})
+		if dryTx.Error != nil {
+			db.AddError(dryTx.Error)
+			return
+		}
+		stmt.SQL.Reset()
+		stmt.SQL.WriteString(dryTx.Statement.SQL.String())
+		stmt.Vars = dryTx.Statement.Vars
+		stmt.Settings.Store(writePreviewKey, &WritePreview{
+			Table:     stmt.Table,
+			Precision: dialector.writePrecision(stmt),
+			SQL:       dialector.Explain(dryTx.Statement.SQL.String(), dryTx.Statement.Vars...),
+		})
+		return
+	}
+
+	rows, err := tx.Rows()
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	declared := tagColumns(stmt.Schema)
+	isTag := make([]bool, len(names))
+	for i, name := range names {
+		isTag[i] = columnTypes[i].DatabaseTypeName() == "TAG" || slices.Contains(declared, name)
+	}
+
+	size := dialector.writeBatchSize(db)
+	points := make([]*influxdb3.Point, 0, size)
+	flush := func() bool {
+		if len(points) == 0 {
+			return true
+		}
+		written, err := dialector.writePoints(stmt.Context, db, points, dialector.writePrecision(stmt))
+		db.RowsAffected += written
+		if err != nil {
+			// 重写的数据点来自查询结果，没有对应的模型
+			var partialErr *PartialWriteError
+			if errors.As(err, &partialErr) {
+				for i := range partialErr.Lines {
+					partialErr.Lines[i].Index = -1
+				}
+			}
+			db.AddError(err)
+			return false
+		}
+		points = points[:0]
+		return true
+	}
+
+	values := make([]interface{}, len(names))
+	for rows.Next() {
+		ptrs := make([]interface{}, len(names))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			db.AddError(err)
+			return
+		}
+
+		tagValues := map[string]string{}
+		var ts time.Time
+		for i, name := range names {
+			if name == timeColumn.Name {
+				ts, _ = timeValue(values[i])
+			} else if !isTag[i] {
+				continue
+			} else if tag, ok := tagValue(values[i]); ok {
+				tagValues[name] = tag
+			}
+		}
+		if ts.IsZero() {
+			db.AddError(fmt.Errorf("查询结果缺少 %s 列，无法定位数据点", timeColumn.Name))
+			return
+		}
+
+		points = append(points, updatePoint(stmt.Table, tagValues, ts, changes))
+		if len(points) >= size && !flush() {
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		db.AddError(err)
+		return
+	}
+	flush()
+}
+
+// identityCondition 返回匹配模型对应数据点的条件：时间戳相同，模型中的 tag 相同，声明但为空的 tag 不存在
+func identityCondition(s *schema.Schema, tags map[string]string, ts time.Time) clause.Expression {
+	exprs := []clause.Expression{clause.Eq{Column: clause.Column{Name: timeColumn.Name}, Value: ts}}
+	declared := tagColumns(s)
+	for _, name := range declared {
+		if tag, ok := tags[name]; ok {
+			exprs = append(exprs, clause.Eq{Column: clause.Column{Name: name}, Value: tag})
+		} else {
+			exprs = append(exprs, clause.Eq{Column: clause.Column{Name: name}, Value: nil})
+		}
+	}
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		if !slices.Contains(declared, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		exprs = append(exprs, clause.Eq{Column: clause.Column{Name: name}, Value: tags[name]})
+	}
+	return clause.And(exprs...)
+}
+
+// updatePoint 构造只包含变更字段的数据点
+func updatePoint(table string, tags map[string]string, ts time.Time, changes map[string]interface{}) *influxdb3.Point {
+	point := influxdb3.NewPointWithMeasurement(table).SetTimestamp(ts)
+	for name, value := range tags {
+		point.SetTag(name, value)
+	}
+	for name, value := range changes {
+		point.SetField(name, value)
+	}
+	return point
+}
+
+// isSave 判断是否为 Save 触发的更新，此时 Dest 就是模型本身
+func isSave(stmt *gorm.Statement) bool {
+	if stmt.Model == nil || stmt.Dest == nil {
+		return false
+	}
+	model, dest := reflect.ValueOf(stmt.Model), reflect.ValueOf(stmt.Dest)
+	return model.Kind() == reflect.Ptr && dest.Kind() == reflect.Ptr && model.Pointer() == dest.Pointer()
+}
+
+// updateChanges 计算要写入的 field，遵循 Select/Omit 的选择
+// 结构体中的零值字段被忽略，tag 和 time 列用于定位数据点，不能被修改。
+// omitZero 为 true 时 Save 同样忽略零值字段，避免把查询时按零值读取的 NULL 写为 0
+func updateChanges(stmt *gorm.Statement, omitZero bool) (map[string]interface{}, error) {
+	selectColumns, restricted := stmt.SelectAndOmitColumns(false, true)
+	selected := func(column string, isZero bool) bool {
+		v, ok := selectColumns[column]
+		if ok {
+			return v
+		}
+		return !restricted && !isZero
+	}
+
+	changes := map[string]interface{}{}
+	add := func(field *schema.Field, column string, value interface{}) error {
+		if expr, ok := value.(clause.Expression); ok {
+			return fmt.Errorf("不支持使用表达式更新 %s: %v", column, expr)
+		}
+		value, err := driverValue(field, value)
+		if err != nil {
+			return err
+		}
+		if v, ok := fieldValue(value); ok {
+			changes[column] = v
+		}
+		return nil
+	}
+
+	switch dest := stmt.Dest.(type) {
+	case map[string]interface{}:
+		for key, value := range dest {
+			column := key
+			field := stmt.Schema.LookUpField(key)
+			if field != nil {
+				if !field.Updatable {
+					continue
+				}
+				if isIdentityField(field) {
+					return nil, fmt.Errorf("不能更新 %s 列，修改 tag 或时间戳会写入新的数据点", field.DBName)
+				}
+				column = field.DBName
+
+				// 按模型字段的类型转换，避免写入与已有 field 类型冲突的值
+				if _, ok := value.(clause.Expression); !ok && value != nil {
+					if overflows(field.IndirectFieldType, value) {
+						return nil, fmt.Errorf("%w: %s 的值 %v 无法放入字段 %s (%s)", ErrValueOutOfRange, column, value, field.Name, field.IndirectFieldType)
+					}
+					tmp := reflect.New(stmt.Schema.ModelType).Elem()
+					if err := field.Set(stmt.Context, tmp, value); err != nil {
+						return nil, err
+					}
+					value, _ = field.ValueOf(stmt.Context, tmp)
+				}
+			}
+			if v, ok := selectColumns[column]; (ok && !v) || (!ok && restricted) {
+				continue
+			}
+			if err := add(field, column, value); err != nil {
+				return nil, err
+			}
+		}
+		return changes, nil
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(stmt.Dest))
+	if rv.Kind() != reflect.Struct || rv.Type() != stmt.Schema.ModelType {
+		return nil, gorm.ErrInvalidData
+	}
+	save := isSave(stmt)
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" || !field.Updatable || field.PrimaryKey {
+			continue
+		}
+		value, isZero := field.ValueOf(stmt.Context, rv)
+		if !selected(field.DBName, isZero) || (save && omitZero && isZero) {
+			continue
+		}
+		if isIdentityField(field) {
+			// Save 时 tag 和时间戳来自模型本身，只用于定位数据点
+			if save {
+				continue
+			}
+			return nil, fmt.Errorf("不能更新 %s 列，修改 tag 或时间戳会写入新的数据点", field.DBName)
+		}
+		if err := add(field, field.DBName, value); err != nil {
+			return nil, err
+		}
+	}
+
+	// Save 时同时重写动态 field
+	if field := dynamicField(stmt.Schema, "fields"); field != nil && save {
+		iter := field.ReflectValueOf(stmt.Context, rv).MapRange()
+		for iter.Next() {
+			if err := add(nil, iter.Key().String(), iter.Value().Interface()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,396 @@
+package dialector
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"github.com/influxdata/line-protocol/v2/lineprotocol"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// defaultWriteBatchSize 未设置 CreateBatchSize 时每次写入的数据点数量
+const defaultWriteBatchSize = 5000
+
+// writeBatchSize 返回每次写入的数据点数量，会话中设置的 CreateBatchSize 优先
+func (dialector *Dialector) writeBatchSize(db *gorm.DB) int {
+	if db.CreateBatchSize > 0 {
+		return db.CreateBatchSize
+	}
+	if dialector.createBatchSize > 0 {
+		return dialector.createBatchSize
+	}
+	return defaultWriteBatchSize
+}
+
+// writePoints 将数据点编码为行协议后按批写入，返回服务端接受的数据点数量
+// 配置了主机地址时通过 HTTP 接口写入，以便获得逐行的错误信息，否则使用客户端写入。
+// 部分写入失败时 WriteLineError.Index 为被拒绝的数据点在 points 中的下标
+func (dialector *Dialector) writePoints(ctx context.Context, db *gorm.DB, points []*influxdb3.Point, precision lineprotocol.Precision) (int64, error) {
+	if dialector.host() == "" && dialector.Client == nil {
+		return 0, errors.New("InfluxDB客户端未初始化")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var written int64
+	var rejected *PartialWriteError
+	size := dialector.writeBatchSize(db)
+	for start := 0; start < len(points); start += size {
+		end := start + size
+		if end > len(points) {
+			end = len(points)
+		}
+
+		var buf []byte
+		lines := make([]string, 0, end-start)
+		for i, point := range points[start:end] {
+			offset := len(buf)
+			var err error
+			if buf, err = appendPoint(buf, point, precision); err != nil {
+				return written, fmt.Errorf("编码第 %d 个数据点: %w", start+i, err)
+			}
+			lines = append(lines, strings.TrimSuffix(string(buf[offset:]), "\n"))
+		}
+
+		var err error
+		if dialector.host() != "" {
+			err = dialector.writeLineProtocol(ctx, buf, precision)
+		} else {
+			err = dialector.Client.Write(ctx, buf, influxdb3.WithPrecision(precision))
+		}
+
+		var partialErr *PartialWriteError
+		if !errors.As(err, &partialErr) {
+			if err != nil {
+				return written, err
+			}
+			written += int64(end - start)
+			continue
+		}
+
+		// 服务端返回的行号从 1 开始，按本批次计算，缺失时按原始行内容查找
+		for i := range partialErr.Lines {
+			line := &partialErr.Lines[i]
+			index := line.Line - 1
+			if index < 0 || index >= len(lines) || (line.Text != "" && line.Text != lines[index]) {
+				index = slices.Index(lines, line.Text)
+			}
+			if index >= 0 {
+				line.Index = start + index
+				line.Line = line.Index + 1
+			} else {
+				line.Index = -1
+			}
+		}
+		// 未开启 AcceptPartial 时服务端不会写入本批次的任何数据点
+		if !dialector.AcceptPartial {
+			return written, partialErr
+		}
+		written += int64(end - start - len(partialErr.Lines))
+		if rejected == nil {
+			rejected = &PartialWriteError{Err: partialErr.Err}
+		}
+		rejected.Lines = append(rejected.Lines, partialErr.Lines...)
+	}
+
+	if rejected != nil {
+		db.Logger.Warn(ctx, "%v", rejected)
+		if dialector.OnPartialWrite != nil {
+			dialector.OnPartialWrite(ctx, rejected)
+		}
+	}
+	return written, nil
+}
+
+// createCallback 替换 GORM 的创建回调，将模型转换为数据点写入
+func (dialector *Dialector) createCallback(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+
+	stmt := db.Statement
+	if stmt.Schema == nil {
+		db.AddError(gorm.ErrModelValueRequired)
+		return
+	}
+
+	var points []*influxdb3.Point
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			point, err := modelPoint(stmt, reflect.Indirect(stmt.ReflectValue.Index(i)), dialector.OmitZeroFields)
+			if err != nil {
+				db.AddError(err)
+				return
+			}
+			points = append(points, point)
+		}
+	case reflect.Struct:
+		point, err := modelPoint(stmt, stmt.ReflectValue, dialector.OmitZeroFields)
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+		points = append(points, point)
+	default:
+		db.AddError(gorm.ErrInvalidValue)
+		return
+	}
+
+	if db.DryRun {
+		previewPoints(db, points, dialector.writePrecision(stmt))
+		return
+	}
+	written, err := dialector.writePoints(stmt.Context, db, points, dialector.writePrecision(stmt))
+	db.RowsAffected = written
+	if err != nil {
+		db.AddError(err)
+	}
+}
+
+// modelPoint 将模型转换为数据点
+// tag 字段写为 tag，时间戳字段作为数据点的时间戳，自增主键的零值被忽略，其余字段写为 field。
+// nil 指针和 Value 返回 nil 的字段不写入，omitZero 为 true 时零值字段同样不写入。
+// 与 GORM 一致，带有 autoCreateTime、autoUpdateTime 的零值字段使用当前时间并写回模型
+func modelPoint(stmt *gorm.Statement, rv reflect.Value, omitZero bool) (*influxdb3.Point, error) {
+	point := influxdb3.NewPointWithMeasurement(stmt.Table)
+	tsField := timestampField(stmt.Schema)
+	if tsField != nil && tsField.DataType != schema.Time {
+		return nil, fmt.Errorf("时间戳字段 %s 必须是 time.Time 类型", tsField.Name)
+	}
+
+	// 动态 tag 和 field 先写入，模型中声明的同名字段优先
+	if err := setDynamic(stmt, rv, point); err != nil {
+		return nil, err
+	}
+
+	var now time.Time
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" || !field.Creatable || isDynamicField(field) {
+			continue
+		}
+
+		value, isZero := field.ValueOf(stmt.Context, rv)
+		if isZero && (field.AutoCreateTime > 0 || field.AutoUpdateTime > 0) && rv.CanAddr() {
+			if now.IsZero() {
+				now = stmt.DB.NowFunc()
+			}
+			if err := field.Set(stmt.Context, rv, now); err != nil {
+				return nil, err
+			}
+			value, isZero = field.ValueOf(stmt.Context, rv)
+		}
+		value, err := driverValue(field, value)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case field == tsField:
+			if t, ok := timeValue(value); ok && !t.IsZero() {
+				point.SetTimestamp(t)
+			}
+		case isTimestampField(field):
+			// influx:time 标记了其他字段时，time 列对应的字段不作为 field 写入
+		case isTagField(field):
+			if tag, ok := tagValue(value); ok {
+				point.SetTag(field.DBName, tag)
+			}
+		case (field.AutoIncrement || omitZero) && isZero:
+		default:
+			if v, ok := fieldValue(value); ok {
+				point.SetField(field.DBName, v)
+			}
+		}
+	}
+
+	if !point.HasFields() {
+		return nil, fmt.Errorf("数据点 %s 至少需要一个 field", stmt.Table)
+	}
+	return point, nil
+}
+
+// identityOf 读取模型中定位数据点的 tag 和时间戳，时间戳为零时返回零值
+func identityOf(stmt *gorm.Statement, rv reflect.Value) (map[string]string, time.Time, error) {
+	tags := map[string]string{}
+	if field := dynamicField(stmt.Schema, "tags"); field != nil {
+		iter := field.ReflectValueOf(stmt.Context, rv).MapRange()
+		for iter.Next() {
+			value, err := driverValue(nil, iter.Value().Interface())
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			if tag, ok := tagValue(value); ok {
+				tags[iter.Key().String()] = tag
+			}
+		}
+	}
+
+	var ts time.Time
+	tsField := timestampField(stmt.Schema)
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" || isDynamicField(field) {
+			continue
+		}
+		if field != tsField && !isTagField(field) {
+			continue
+		}
+		value, _ := field.ValueOf(stmt.Context, rv)
+		value, err := driverValue(field, value)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if field == tsField {
+			ts, _ = timeValue(value)
+		} else if tag, ok := tagValue(value); ok {
+			tags[field.DBName] = tag
+		}
+	}
+	return tags, ts, nil
+}
+
+// setDynamic 将 `influx:tags` 和 `influx:fields` 标记的 map 展开为数据点的 tag 和 field
+func setDynamic(stmt *gorm.Statement, rv reflect.Value, point *influxdb3.Point) error {
+	if field := dynamicField(stmt.Schema, "tags"); field != nil {
+		iter := field.ReflectValueOf(stmt.Context, rv).MapRange()
+		for iter.Next() {
+			value, err := driverValue(nil, iter.Value().Interface())
+			if err != nil {
+				return err
+			}
+			if tag, ok := tagValue(value); ok {
+				point.SetTag(iter.Key().String(), tag)
+			}
+		}
+	}
+	if field := dynamicField(stmt.Schema, "fields"); field != nil {
+		iter := field.ReflectValueOf(stmt.Context, rv).MapRange()
+		for iter.Next() {
+			name := iter.Key().String()
+			if _, exists := point.GetTag(name); exists {
+				return fmt.Errorf("动态 field %s 与 tag 同名", name)
+			}
+			value, err := driverValue(nil, iter.Value().Interface())
+			if err != nil {
+				return err
+			}
+			if v, ok := fieldValue(value); ok {
+				point.SetField(name, v)
+			}
+		}
+	}
+	return nil
+}
+
+// timeValue 将字段值转换为时间戳
+func timeValue(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case *time.Time:
+		if v != nil {
+			return *v, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// tagValue 将字段值转换为 tag 值，空值不写入
+func tagValue(value interface{}) (string, bool) {
+	v, ok := fieldValue(value)
+	if !ok {
+		return "", false
+	}
+	tag := fmt.Sprint(v)
+	return tag, tag != ""
+}
+
+// fieldValue 解引用指针，nil 不写入
+func fieldValue(value interface{}) (interface{}, bool) {
+	if value == nil {
+		return nil, false
+	}
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+	return rv.Interface(), true
+}
+
+// driverValue 取得 driver.Valuer 的值，包括 GORM 为 serializer 字段包装的值，nil 指针不写入
+// 结果按 DataTypeOf 使用的字段类型转换，例如用 type:uint 声明的字段，Valuer 返回 int64 时仍写为无符号整数
+func driverValue(field *schema.Field, value interface{}) (interface{}, error) {
+	valuer, ok := value.(driver.Valuer)
+	if !ok {
+		return value, nil
+	}
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil, nil
+	}
+	v, err := valuer.Value()
+	if err != nil || v == nil || field == nil {
+		return v, err
+	}
+
+	var target reflect.Type
+	switch field.DataType {
+	case schema.Int:
+		target = reflect.TypeOf(int64(0))
+	case schema.Uint:
+		target = reflect.TypeOf(uint64(0))
+	case schema.Float:
+		target = reflect.TypeOf(float64(0))
+	case schema.String:
+		if b, ok := v.([]byte); ok {
+			return string(b), nil
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		if overflows(target, v) {
+			return nil, fmt.Errorf("%w: 字段 %s 的值 %v 无法写为 %s", ErrValueOutOfRange, field.Name, v, target)
+		}
+		return rv.Convert(target).Interface(), nil
+	}
+	return v, nil
+}
+
+// isIdentityField 判断字段是否用于定位数据点，更新时不能修改
+func isIdentityField(field *schema.Field) bool {
+	return isTimestampField(field) || isTagField(field)
+}
+
+// writePrecision 返回写入时间戳的精度
+// 模型实现的 WritePrecisioner 优先，其次是 Config.WritePrecision，
+// 未设置时 DisableNanoTimestamps 按微秒写入，默认按纳秒写入
+func (dialector *Dialector) writePrecision(stmt *gorm.Statement) lineprotocol.Precision {
+	if stmt.Schema != nil {
+		if precision, ok := modelWritePrecision(stmt.Schema); ok {
+			return precision
+		}
+	}
+	if dialector.WritePrecision != lineprotocol.Nanosecond {
+		return dialector.WritePrecision
+	}
+	if dialector.DisableNanoTimestamps {
+		return lineprotocol.Microsecond
+	}
+	return lineprotocol.Nanosecond
+}
@@ -1,8 +1,14 @@
 package influxdb3gorm
 
 import (
+	"context"
+	"iter"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
 	"github.com/xiabin827/influxdb3-gorm-driver/dialector"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Open 打开InfluxDB3数据库连接
@@ -15,3 +21,76 @@ func Open(dsn string) gorm.Dialector {
 func New(config dialector.Config) gorm.Dialector {
 	return dialector.New(config)
 }
+
+// LastValues 返回从 Last Value Cache 读取数据的 scope
+func LastValues(name string) func(*gorm.DB) *gorm.DB {
+	return dialector.LastValues(name)
+}
+
+// DistinctValues 返回从 Distinct Value Cache 读取数据的 scope
+func DistinctValues(name string) func(*gorm.DB) *gorm.DB {
+	return dialector.DistinctValues(name)
+}
+
+// DistinctTagValues 查询模型某一列的所有不同取值，优先使用 Distinct Value Cache
+func DistinctTagValues(db *gorm.DB, model interface{}, column string) ([]string, error) {
+	return dialector.DistinctTagValues(db, model, column)
+}
+
+// TimeBucket 返回按固定间隔对时间列分桶的 scope
+func TimeBucket(interval time.Duration, column string, opts ...dialector.TimeBucketOptions) func(*gorm.DB) *gorm.DB {
+	return dialector.TimeBucket(interval, column, opts...)
+}
+
+// GapFill 返回使用 date_bin_gapfill 分桶并补齐空时间桶的 scope
+func GapFill(interval time.Duration, column string, opts ...dialector.TimeBucketOptions) func(*gorm.DB) *gorm.DB {
+	return dialector.GapFill(interval, column, opts...)
+}
+
+// Interpolate 返回对缺失时间桶做线性插值的表达式
+func Interpolate(expr string) clause.Expr {
+	return dialector.Interpolate(expr)
+}
+
+// LOCF 返回使用上一个非空值填充缺失时间桶的表达式
+func LOCF(expr string) clause.Expr {
+	return dialector.LOCF(expr)
+}
+
+// Ident 返回不按点号拆分的标识符，用于名称本身包含点号的表或列
+func Ident(names ...string) clause.Expr {
+	return dialector.Ident(names...)
+}
+
+// Between 返回限定 time 列位于 [start, end) 区间的 scope
+func Between(start, end time.Time) func(*gorm.DB) *gorm.DB {
+	return dialector.Between(start, end)
+}
+
+// Since 返回限定 time 列不早于服务端当前时间之前 d 的 scope
+func Since(d time.Duration) func(*gorm.DB) *gorm.DB {
+	return dialector.Since(d)
+}
+
+// Last 返回限定 time 列位于服务端当前时间之前 d 时间内的 scope
+func Last(d time.Duration) func(*gorm.DB) *gorm.DB {
+	return dialector.Last(d)
+}
+
+// QueryTypeKey 会话级查询语言的设置键，取值为 "sql" 或 "influxql"
+const QueryTypeKey = dialector.QueryTypeKey
+
+// Stream 以迭代器的方式逐行读取查询结果，不会将全部结果加载到内存
+func Stream[T any](db *gorm.DB) iter.Seq2[T, error] {
+	return dialector.Stream[T](db)
+}
+
+// QueryArrow 执行查询并直接返回 Arrow 记录批次
+func QueryArrow(ctx context.Context, db *gorm.DB) (array.RecordReader, error) {
+	return dialector.QueryArrow(ctx, db)
+}
+
+// Preview 返回 DryRun 会话中写入操作生成的预览
+func Preview(db *gorm.DB) (*dialector.WritePreview, bool) {
+	return dialector.Preview(db)
+}
@@ -0,0 +1,380 @@
+package influxdb3test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// lastCache Last Value Cache 的定义
+// 测试服务端不单独保存缓存的值，查询时按表中当前的数据计算
+type lastCache struct {
+	table        string
+	name         string
+	keyColumns   []string
+	valueColumns []string // 为空时缓存所有非键列
+	count        uint64
+	ttl          uint64 // 秒
+}
+
+// 与服务端一致的默认值
+const (
+	defaultLastCacheCount = 1
+	defaultLastCacheTTL   = 4 * 60 * 60
+)
+
+// handleCreateLastCache 创建 Last Value Cache，键列默认为表的全部 tag 列
+func (s *Server) handleCreateLastCache(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DB           string   `json:"db"`
+		Table        string   `json:"table"`
+		Name         string   `json:"name"`
+		KeyColumns   []string `json:"key_columns"`
+		ValueColumns []string `json:"value_columns"`
+		Count        uint64   `json:"count"`
+		TTL          uint64   `json:"ttl"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, t, ok := s.lookupTable(w, req.DB, req.Table)
+	if !ok {
+		return
+	}
+
+	cache := &lastCache{
+		table:        req.Table,
+		name:         req.Name,
+		keyColumns:   req.KeyColumns,
+		valueColumns: req.ValueColumns,
+		count:        req.Count,
+		ttl:          req.TTL,
+	}
+	if len(cache.keyColumns) == 0 {
+		for _, c := range t.sortedColumns() {
+			if c.kind == kindTag {
+				cache.keyColumns = append(cache.keyColumns, c.name)
+			}
+		}
+	}
+	if cache.name == "" {
+		cache.name = strings.Join(append(append([]string{req.Table}, cache.keyColumns...), "last_cache"), "_")
+	}
+	if cache.count == 0 {
+		cache.count = defaultLastCacheCount
+	}
+	if cache.ttl == 0 {
+		cache.ttl = defaultLastCacheTTL
+	}
+	for _, name := range append(slices.Clone(cache.keyColumns), cache.valueColumns...) {
+		if _, ok := t.columns[name]; !ok {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid column: %s", name))
+			return
+		}
+	}
+	if slices.ContainsFunc(db.lastCaches, func(c *lastCache) bool { return c.table == cache.table && c.name == cache.name }) {
+		writeError(w, http.StatusConflict, fmt.Sprintf("attempted to create cache that already exists: %s", cache.name))
+		return
+	}
+
+	db.lastCaches = append(db.lastCaches, cache)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handleDeleteLastCache 删除 Last Value Cache
+func (s *Server) handleDeleteLastCache(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, _, ok := s.lookupTable(w, query.Get("db"), query.Get("table"))
+	if !ok {
+		return
+	}
+	index := slices.IndexFunc(db.lastCaches, func(c *lastCache) bool {
+		return c.table == query.Get("table") && c.name == query.Get("name")
+	})
+	if index < 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("cache not found: %s", query.Get("name")))
+		return
+	}
+	db.lastCaches = slices.Delete(db.lastCaches, index, index+1)
+	w.WriteHeader(http.StatusOK)
+}
+
+// lookupTable 查找数据库和表，不存在时返回 404
+func (s *Server) lookupTable(w http.ResponseWriter, name, table string) (*database, *table, bool) {
+	db, ok := s.databases[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("database not found: %s", name))
+		return nil, nil, false
+	}
+	t, ok := db.tables[table]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("table not found: %s", table))
+		return nil, nil, false
+	}
+	return db, t, true
+}
+
+// systemLastCaches 返回 system.last_caches 的内容
+func systemLastCaches(db *database) *relation {
+	rel := &relation{name: "last_caches", columns: append(stringColumns("table", "name"),
+		&column{name: "key_column_names", typ: typeString | listFlag},
+		&column{name: "value_column_names", typ: typeString | listFlag},
+		&column{name: "count", typ: typeUint},
+		&column{name: "ttl", typ: typeUint},
+	)}
+	for _, c := range db.lastCaches {
+		var values any
+		if len(c.valueColumns) > 0 {
+			values = stringList(c.valueColumns)
+		}
+		rel.rows = append(rel.rows, map[string]any{
+			"table": c.table, "name": c.name, "key_column_names": stringList(c.keyColumns),
+			"value_column_names": values, "count": c.count, "ttl": c.ttl,
+		})
+	}
+	return rel
+}
+
+// stringList 将字符串切片转换为列表类型的值
+func stringList(values []string) []any {
+	list := make([]any, len(values))
+	for i, v := range values {
+		list[i] = v
+	}
+	return list
+}
+
+// findCache 按表名和缓存名称查找缓存，名称为空且表上只有一个缓存时返回该缓存
+func findCache[T any](caches []T, table, name string, key func(T) (string, string)) (T, error) {
+	var found []T
+	for _, c := range caches {
+		if t, n := key(c); t == table && (name == "" || n == name) {
+			found = append(found, c)
+		}
+	}
+	var zero T
+	switch {
+	case len(found) == 0:
+		return zero, fmt.Errorf("Error during planning: cache not found on table %s", table)
+	case len(found) > 1:
+		return zero, fmt.Errorf("Error during planning: multiple caches on table %s, a cache name must be specified", table)
+	}
+	return found[0], nil
+}
+
+// lastCacheRelation 返回 last_cache() 表函数的结果
+// 每个键按时间从新到旧保留 count 行，键列为 NULL 的数据点不会进入缓存
+func lastCacheRelation(db *database, tableName, name string) (*relation, error) {
+	cache, err := findCache(db.lastCaches, tableName, name, func(c *lastCache) (string, string) { return c.table, c.name })
+	if err != nil {
+		return nil, err
+	}
+	t, ok := db.tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("Error during planning: table 'public.iox.%s' not found", tableName)
+	}
+	base := tableRelation(t)
+
+	rel := &relation{name: t.name, iox: true}
+	for _, name := range cache.keyColumns {
+		rel.columns = append(rel.columns, t.columns[name])
+	}
+	for _, c := range base.columns {
+		isKey := slices.Contains(cache.keyColumns, c.name)
+		isValue := len(cache.valueColumns) == 0 || slices.Contains(cache.valueColumns, c.name) || c.kind == kindTime
+		if !isKey && isValue {
+			rel.columns = append(rel.columns, c)
+		}
+	}
+
+	groups := map[string][]map[string]any{}
+	var keys []string
+	for i := len(base.rows) - 1; i >= 0; i-- {
+		row := base.rows[i]
+		var key strings.Builder
+		complete := true
+		for _, name := range cache.keyColumns {
+			if row[name] == nil {
+				complete = false
+				break
+			}
+			fmt.Fprintf(&key, "%v\x00", row[name])
+		}
+		if !complete {
+			continue
+		}
+		k := key.String()
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		if uint64(len(groups[k])) < cache.count {
+			groups[k] = append(groups[k], row)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		rel.rows = append(rel.rows, groups[k]...)
+	}
+	return rel, nil
+}
+
+// distinctCache Distinct Value Cache 的定义
+type distinctCache struct {
+	table          string
+	name           string
+	columns        []string
+	maxCardinality uint64
+	maxAge         uint64 // 秒
+}
+
+// 与服务端一致的默认值
+const (
+	defaultDistinctCacheMaxCardinality = 100000
+	defaultDistinctCacheMaxAge         = 24 * 60 * 60
+)
+
+// handleCreateDistinctCache 创建 Distinct Value Cache，列只能是 tag 或字符串 field
+func (s *Server) handleCreateDistinctCache(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DB             string   `json:"db"`
+		Table          string   `json:"table"`
+		Name           string   `json:"name"`
+		Columns        []string `json:"columns"`
+		MaxCardinality uint64   `json:"max_cardinality"`
+		MaxAge         uint64   `json:"max_age"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, t, ok := s.lookupTable(w, req.DB, req.Table)
+	if !ok {
+		return
+	}
+	if len(req.Columns) == 0 {
+		writeError(w, http.StatusBadRequest, "must provide at least one column")
+		return
+	}
+	for _, name := range req.Columns {
+		if c, ok := t.columns[name]; !ok || c.typ != typeString {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid column: %s", name))
+			return
+		}
+	}
+
+	cache := &distinctCache{
+		table:          req.Table,
+		name:           req.Name,
+		columns:        req.Columns,
+		maxCardinality: req.MaxCardinality,
+		maxAge:         req.MaxAge,
+	}
+	if cache.name == "" {
+		cache.name = strings.Join(append(append([]string{req.Table}, cache.columns...), "distinct_cache"), "_")
+	}
+	if cache.maxCardinality == 0 {
+		cache.maxCardinality = defaultDistinctCacheMaxCardinality
+	}
+	if cache.maxAge == 0 {
+		cache.maxAge = defaultDistinctCacheMaxAge
+	}
+	if slices.ContainsFunc(db.distinctCaches, func(c *distinctCache) bool { return c.table == cache.table && c.name == cache.name }) {
+		writeError(w, http.StatusConflict, fmt.Sprintf("attempted to create cache that already exists: %s", cache.name))
+		return
+	}
+
+	db.distinctCaches = append(db.distinctCaches, cache)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handleDeleteDistinctCache 删除 Distinct Value Cache
+func (s *Server) handleDeleteDistinctCache(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, _, ok := s.lookupTable(w, query.Get("db"), query.Get("table"))
+	if !ok {
+		return
+	}
+	index := slices.IndexFunc(db.distinctCaches, func(c *distinctCache) bool {
+		return c.table == query.Get("table") && c.name == query.Get("name")
+	})
+	if index < 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("cache not found: %s", query.Get("name")))
+		return
+	}
+	db.distinctCaches = slices.Delete(db.distinctCaches, index, index+1)
+	w.WriteHeader(http.StatusOK)
+}
+
+// systemDistinctCaches 返回 system.distinct_caches 的内容
+func systemDistinctCaches(db *database) *relation {
+	rel := &relation{name: "distinct_caches", columns: append(stringColumns("table", "name"),
+		&column{name: "column_names", typ: typeString | listFlag},
+		&column{name: "max_cardinality", typ: typeUint},
+		&column{name: "max_age_seconds", typ: typeUint},
+	)}
+	for _, c := range db.distinctCaches {
+		rel.rows = append(rel.rows, map[string]any{
+			"table": c.table, "name": c.name, "column_names": stringList(c.columns),
+			"max_cardinality": c.maxCardinality, "max_age_seconds": c.maxAge,
+		})
+	}
+	return rel
+}
+
+// distinctCacheRelation 返回 distinct_cache() 表函数的结果
+// 缓存的列按层级排列，第一列为 NULL 的数据点不会进入缓存
+func distinctCacheRelation(db *database, tableName, name string) (*relation, error) {
+	cache, err := findCache(db.distinctCaches, tableName, name, func(c *distinctCache) (string, string) { return c.table, c.name })
+	if err != nil {
+		return nil, err
+	}
+	t, ok := db.tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("Error during planning: table 'public.iox.%s' not found", tableName)
+	}
+
+	rel := &relation{name: t.name, columns: stringColumns(cache.columns...)}
+	seen := map[string]bool{}
+	for _, row := range tableRelation(t).rows {
+		if row[cache.columns[0]] == nil {
+			continue
+		}
+		var key strings.Builder
+		values := make(map[string]any, len(cache.columns))
+		for _, name := range cache.columns {
+			values[name] = row[name]
+			fmt.Fprintf(&key, "%v\x00", row[name])
+		}
+		if !seen[key.String()] {
+			seen[key.String()] = true
+			rel.rows = append(rel.rows, values)
+		}
+	}
+	sort.SliceStable(rel.rows, func(i, j int) bool {
+		for _, name := range cache.columns {
+			a, _ := rel.rows[i][name].(string)
+			b, _ := rel.rows[j][name].(string)
+			if a != b {
+				return a < b
+			}
+		}
+		return false
+	})
+	return rel, nil
+}
@@ -0,0 +1,1302 @@
+package influxdb3test
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// relation 查询的数据来源
+type relation struct {
+	name    string
+	alias   string
+	columns []*column
+	rows    []map[string]any
+	iox     bool // 是否为用户写入的表，输出列时带上 iox::column::type 元数据
+}
+
+// lookup 查找列，带表名限定时表名必须与 FROM 中的表名或别名一致
+func (r *relation) lookup(ref *colRef) (*column, error) {
+	if ref.table == "" || ref.table == r.name || (r.alias != "" && ref.table == r.alias) {
+		for _, c := range r.columns {
+			if c.name == ref.name {
+				return c, nil
+			}
+		}
+	}
+	name := ref.name
+	if ref.table != "" {
+		name = ref.table + "." + ref.name
+	}
+	return nil, fmt.Errorf("Schema error: No field named %s.", name)
+}
+
+// outputColumn 查询结果中的一列
+type outputColumn struct {
+	name   string
+	typ    dataType
+	source *column // 直接引用表中的列时不为空，用于输出列的元数据
+}
+
+// result 查询结果
+type result struct {
+	columns []outputColumn
+	rows    [][]any
+	iox     bool
+}
+
+// execute 在数据库上执行一条 SELECT 语句，错误信息模仿服务端返回的英文信息
+func execute(db *database, sql string, now time.Time) (*result, error) {
+	stmt, err := parseSelect(sql)
+	if err != nil {
+		return nil, fmt.Errorf("SQL error: ParserError(%q)", err.Error())
+	}
+	rel, err := resolve(db, stmt.from)
+	if err != nil {
+		return nil, err
+	}
+	q := &query{stmt: stmt, rel: rel, now: now}
+	return q.run()
+}
+
+// resolve 返回 FROM 子句对应的数据
+func resolve(db *database, ref *tableRef) (*relation, error) {
+	if ref == nil {
+		return &relation{rows: []map[string]any{{}}}, nil
+	}
+
+	if ref.call {
+		rel, err := tableFunction(db, ref)
+		if err != nil {
+			return nil, err
+		}
+		rel.alias = ref.alias
+		return rel, nil
+	}
+
+	var rel *relation
+	switch ref.schema {
+	case "system":
+		switch ref.name {
+		case "last_caches":
+			rel = systemLastCaches(db)
+		case "distinct_caches":
+			rel = systemDistinctCaches(db)
+		}
+	case "information_schema":
+		switch ref.name {
+		case "tables":
+			rel = informationSchemaTables(db)
+		case "columns":
+			rel = informationSchemaColumns(db)
+		}
+	case "", "iox":
+		if t, ok := db.tables[ref.name]; ok {
+			rel = tableRelation(t)
+		}
+	}
+	if rel == nil {
+		schema := ref.schema
+		if schema == "" {
+			schema = "iox"
+		}
+		return nil, fmt.Errorf("Error during planning: table 'public.%s.%s' not found", schema, ref.name)
+	}
+	rel.alias = ref.alias
+	return rel, nil
+}
+
+// tableFunction 返回 FROM 子句中表函数的结果，参数只能是字符串字面量
+func tableFunction(db *database, ref *tableRef) (*relation, error) {
+	args := make([]string, len(ref.args))
+	for i, arg := range ref.args {
+		l, ok := arg.(*literal)
+		if !ok {
+			return nil, fmt.Errorf("Error during planning: %s expects string literal arguments", ref.name)
+		}
+		if args[i], ok = l.value.(string); !ok {
+			return nil, fmt.Errorf("Error during planning: %s expects string literal arguments", ref.name)
+		}
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("Error during planning: %s expects 1 or 2 arguments", ref.name)
+	}
+	args = append(args, "")
+
+	switch ref.name {
+	case "last_cache":
+		return lastCacheRelation(db, args[0], args[1])
+	case "distinct_cache":
+		return distinctCacheRelation(db, args[0], args[1])
+	}
+	return nil, fmt.Errorf("Error during planning: table function '%s' not found", ref.name)
+}
+
+// tableRelation 将表中的数据点转换为行，缺失的 tag 和 field 为 NULL
+func tableRelation(t *table) *relation {
+	rel := &relation{name: t.name, columns: t.sortedColumns(), iox: true}
+	for _, p := range t.sortedPoints() {
+		row := make(map[string]any, len(rel.columns))
+		for _, c := range rel.columns {
+			switch c.kind {
+			case kindTime:
+				row[c.name] = time.Unix(0, p.time).UTC()
+			case kindTag:
+				if v, ok := p.tags[c.name]; ok {
+					row[c.name] = v
+				} else {
+					row[c.name] = nil
+				}
+			default:
+				row[c.name] = p.fields[c.name]
+			}
+		}
+		rel.rows = append(rel.rows, row)
+	}
+	return rel
+}
+
+// stringColumns 创建字符串类型的列
+func stringColumns(names ...string) []*column {
+	columns := make([]*column, len(names))
+	for i, name := range names {
+		columns[i] = &column{name: name, typ: typeString}
+	}
+	return columns
+}
+
+// sortedTables 按名称排序返回数据库中的表
+func (db *database) sortedTables() []*table {
+	tables := make([]*table, 0, len(db.tables))
+	for _, t := range db.tables {
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].name < tables[j].name })
+	return tables
+}
+
+// informationSchemaTables 返回 information_schema.tables 的内容
+func informationSchemaTables(db *database) *relation {
+	rel := &relation{name: "tables", columns: stringColumns("table_catalog", "table_schema", "table_name", "table_type")}
+	for _, t := range db.sortedTables() {
+		rel.rows = append(rel.rows, map[string]any{
+			"table_catalog": "public", "table_schema": "iox", "table_name": t.name, "table_type": "BASE TABLE",
+		})
+	}
+	for _, name := range []string{"columns", "tables"} {
+		rel.rows = append(rel.rows, map[string]any{
+			"table_catalog": "public", "table_schema": "information_schema", "table_name": name, "table_type": "VIEW",
+		})
+	}
+	return rel
+}
+
+// informationSchemaColumns 返回 information_schema.columns 的内容
+func informationSchemaColumns(db *database) *relation {
+	rel := &relation{
+		name:    "columns",
+		columns: stringColumns("table_catalog", "table_schema", "table_name", "column_name", "data_type", "is_nullable"),
+	}
+	for _, t := range db.sortedTables() {
+		for _, c := range t.sortedColumns() {
+			nullable := "YES"
+			if c.kind == kindTime {
+				nullable = "NO"
+			}
+			rel.rows = append(rel.rows, map[string]any{
+				"table_catalog": "public", "table_schema": "iox", "table_name": t.name,
+				"column_name": c.name, "data_type": arrowTypeName(c), "is_nullable": nullable,
+			})
+		}
+	}
+	return rel
+}
+
+// arrowTypeName 返回列在 information_schema 中显示的类型名称
+func arrowTypeName(c *column) string {
+	switch {
+	case c.kind == kindTag:
+		return "Dictionary(Int32, Utf8)"
+	case c.kind == kindTime:
+		return "Timestamp(Nanosecond, None)"
+	}
+	switch c.typ {
+	case typeInt:
+		return "Int64"
+	case typeUint:
+		return "UInt64"
+	case typeFloat:
+		return "Float64"
+	case typeBool:
+		return "Boolean"
+	}
+	return "Utf8"
+}
+
+// query 一次查询的执行状态
+type query struct {
+	stmt *selectStmt
+	rel  *relation
+	now  time.Time
+}
+
+// env 表达式求值时的上下文
+type env struct {
+	row   map[string]any
+	group []map[string]any // 聚合查询中当前分组的全部行
+}
+
+// projection 展开 * 后的输出列
+type projection struct {
+	expr   expr
+	output outputColumn
+}
+
+func (q *query) run() (*result, error) {
+	stmt := q.stmt
+	projections, err := q.projections()
+	if err != nil {
+		return nil, err
+	}
+
+	if stmt.where != nil {
+		if _, err := q.typeOf(stmt.where, false); err != nil {
+			return nil, err
+		}
+	}
+	rows := make([]map[string]any, 0, len(q.rel.rows))
+	for _, row := range q.rel.rows {
+		if stmt.where == nil {
+			rows = append(rows, row)
+			continue
+		}
+		v, err := q.eval(stmt.where, env{row: row})
+		if err != nil {
+			return nil, err
+		}
+		if v == true {
+			rows = append(rows, row)
+		}
+	}
+
+	aggregate := len(stmt.groupBy) > 0
+	for _, p := range projections {
+		aggregate = aggregate || hasAggregate(p.expr)
+	}
+
+	// 每个输出行对应的求值上下文，用于计算 ORDER BY
+	var envs []env
+	if aggregate {
+		envs, err = q.groups(rows, projections)
+	} else {
+		for _, row := range rows {
+			envs = append(envs, env{row: row})
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := &result{iox: q.rel.iox}
+	for _, p := range projections {
+		res.columns = append(res.columns, p.output)
+	}
+	for _, e := range envs {
+		values := make([]any, len(projections))
+		for i, p := range projections {
+			if values[i], err = q.eval(p.expr, e); err != nil {
+				return nil, err
+			}
+		}
+		res.rows = append(res.rows, values)
+	}
+
+	if err := q.order(res, envs, projections); err != nil {
+		return nil, err
+	}
+	if stmt.distinct {
+		res.rows = distinctRows(res.rows)
+	}
+
+	if stmt.offset > 0 {
+		res.rows = res.rows[min(int(stmt.offset), len(res.rows)):]
+	}
+	if stmt.limit >= 0 && int(stmt.limit) < len(res.rows) {
+		res.rows = res.rows[:stmt.limit]
+	}
+
+	for i := range res.columns {
+		c := &res.columns[i]
+		for _, row := range res.rows {
+			row[i] = coerce(row[i], c.typ)
+		}
+		if c.typ == typeNull {
+			for _, row := range res.rows {
+				if row[i] != nil {
+					c.typ = typeOfValue(row[i])
+					break
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// projections 展开 SELECT 列表中的 *，并推断每一列的类型
+func (q *query) projections() ([]projection, error) {
+	var projections []projection
+	for _, item := range q.stmt.items {
+		if item.star {
+			for _, c := range q.rel.columns {
+				projections = append(projections, projection{
+					expr:   &colRef{name: c.name},
+					output: outputColumn{name: c.name, typ: c.typ, source: c},
+				})
+			}
+			continue
+		}
+
+		typ, err := q.typeOf(item.expr, true)
+		if err != nil {
+			return nil, err
+		}
+		output := outputColumn{name: item.alias, typ: typ}
+		if ref, ok := item.expr.(*colRef); ok {
+			output.source, _ = q.rel.lookup(ref)
+		}
+		if output.name == "" {
+			output.name = item.expr.String()
+		}
+		projections = append(projections, projection{expr: item.expr, output: output})
+	}
+	return projections, nil
+}
+
+// groups 按 GROUP BY 分组，没有 GROUP BY 时全部行作为一组
+func (q *query) groups(rows []map[string]any, projections []projection) ([]env, error) {
+	grouped := map[string]bool{}
+	for _, e := range q.stmt.groupBy {
+		if _, err := q.typeOf(e, false); err != nil {
+			return nil, err
+		}
+		grouped[e.String()] = true
+	}
+	for _, p := range projections {
+		if err := checkGrouped(p.expr, grouped); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(q.stmt.groupBy) == 0 {
+		empty := map[string]any{}
+		for _, c := range q.rel.columns {
+			empty[c.name] = nil
+		}
+		e := env{row: empty, group: rows}
+		if len(rows) > 0 {
+			e.row = rows[0]
+		}
+		return []env{e}, nil
+	}
+
+	var envs []env
+	index := map[string]int{}
+	for _, row := range rows {
+		var key strings.Builder
+		for _, g := range q.stmt.groupBy {
+			v, err := q.eval(g, env{row: row})
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&key, "%T:%v|", v, v)
+		}
+		i, ok := index[key.String()]
+		if !ok {
+			i = len(envs)
+			index[key.String()] = i
+			envs = append(envs, env{row: row})
+		}
+		envs[i].group = append(envs[i].group, row)
+	}
+	return envs, nil
+}
+
+// checkGrouped 检查聚合查询中聚合函数之外的列都出现在 GROUP BY 中
+func checkGrouped(e expr, grouped map[string]bool) error {
+	if grouped[e.String()] {
+		return nil
+	}
+	switch x := e.(type) {
+	case *colRef:
+		return fmt.Errorf("Error during planning: Projection references non-aggregate values: Expression %s could not be resolved from available columns", x.name)
+	case *funcCall:
+		if isAggregate(x.name) {
+			return nil
+		}
+	}
+	for _, child := range children(e) {
+		if err := checkGrouped(child, grouped); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// order 按 ORDER BY 排序，升序时 NULL 排在最后，降序时排在最前
+func (q *query) order(res *result, envs []env, projections []projection) error {
+	if len(q.stmt.orderBy) == 0 {
+		return nil
+	}
+
+	keys := make([][]any, len(res.rows))
+	for i := range res.rows {
+		keys[i] = make([]any, len(q.stmt.orderBy))
+	}
+	for j, item := range q.stmt.orderBy {
+		index := -1
+		for k, p := range projections {
+			if ref, ok := item.expr.(*colRef); ok && ref.table == "" && ref.name == p.output.name {
+				index = k
+				break
+			}
+			if item.expr.String() == p.expr.String() {
+				index = k
+			}
+		}
+		if index < 0 {
+			if _, err := q.typeOf(item.expr, len(envs) > 0 && envs[0].group != nil); err != nil {
+				return err
+			}
+		}
+		for i := range res.rows {
+			if index >= 0 {
+				keys[i][j] = res.rows[i][index]
+				continue
+			}
+			v, err := q.eval(item.expr, envs[i])
+			if err != nil {
+				return err
+			}
+			keys[i][j] = v
+		}
+	}
+
+	order := make([]int, len(res.rows))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		for j, item := range q.stmt.orderBy {
+			x, y := keys[order[a]][j], keys[order[b]][j]
+			var c int
+			switch {
+			case x == nil && y == nil:
+				continue
+			case x == nil:
+				c = 1
+			case y == nil:
+				c = -1
+			default:
+				c, _ = compare(x, y)
+			}
+			if item.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	rows := make([][]any, len(order))
+	for i, k := range order {
+		rows[i] = res.rows[k]
+	}
+	res.rows = rows
+	return nil
+}
+
+// distinctRows 去掉重复的行，保留第一次出现的顺序
+func distinctRows(rows [][]any) [][]any {
+	seen := map[string]bool{}
+	result := rows[:0]
+	for _, row := range rows {
+		var key strings.Builder
+		for _, v := range row {
+			fmt.Fprintf(&key, "%T:%v|", v, v)
+		}
+		if !seen[key.String()] {
+			seen[key.String()] = true
+			result = append(result, row)
+		}
+	}
+	return result
+}
+
+// children 返回表达式的子表达式
+func children(e expr) []expr {
+	switch x := e.(type) {
+	case *binaryExpr:
+		return []expr{x.left, x.right}
+	case *unaryExpr:
+		return []expr{x.x}
+	case *inExpr:
+		return append([]expr{x.x}, x.list...)
+	case *isNullExpr:
+		return []expr{x.x}
+	case *betweenExpr:
+		return []expr{x.x, x.low, x.high}
+	case *likeExpr:
+		return []expr{x.x, x.pattern}
+	case *funcCall:
+		return x.args
+	}
+	return nil
+}
+
+// isAggregate 判断是否为聚合函数
+func isAggregate(name string) bool {
+	switch name {
+	case "count", "sum", "avg", "mean", "min", "max", "approx_percentile_cont", "array_agg":
+		return true
+	}
+	return false
+}
+
+// hasAggregate 判断表达式中是否包含聚合函数
+func hasAggregate(e expr) bool {
+	if f, ok := e.(*funcCall); ok && isAggregate(f.name) {
+		return true
+	}
+	for _, child := range children(e) {
+		if hasAggregate(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf 推断表达式的类型，同时检查引用的列是否存在
+func (q *query) typeOf(e expr, allowAggregate bool) (dataType, error) {
+	switch x := e.(type) {
+	case *colRef:
+		c, err := q.rel.lookup(x)
+		if err != nil {
+			return typeNull, err
+		}
+		return c.typ, nil
+	case *literal:
+		if _, ok := x.value.(time.Duration); ok {
+			return typeInterval, nil
+		}
+		return typeOfValue(x.value), nil
+	case *binaryExpr:
+		left, err := q.typeOf(x.left, allowAggregate)
+		if err != nil {
+			return typeNull, err
+		}
+		right, err := q.typeOf(x.right, allowAggregate)
+		if err != nil {
+			return typeNull, err
+		}
+		switch x.op {
+		case "AND", "OR", "=", "!=", "<", "<=", ">", ">=":
+			return typeBool, nil
+		}
+		return arithmeticType(x.op, left, right)
+	case *unaryExpr:
+		t, err := q.typeOf(x.x, allowAggregate)
+		if x.op == "NOT" {
+			return typeBool, err
+		}
+		return t, err
+	case *funcCall:
+		if isAggregate(x.name) && !allowAggregate {
+			return typeNull, fmt.Errorf("Error during planning: aggregate function %s is not allowed here", x.name)
+		}
+		var argTypes []dataType
+		for _, arg := range x.args {
+			t, err := q.typeOf(arg, false)
+			if err != nil {
+				return typeNull, err
+			}
+			argTypes = append(argTypes, t)
+		}
+		return functionType(x, argTypes)
+	}
+
+	for _, child := range children(e) {
+		if _, err := q.typeOf(child, allowAggregate); err != nil {
+			return typeNull, err
+		}
+	}
+	return typeBool, nil
+}
+
+// arithmeticType 推断算术运算结果的类型
+func arithmeticType(op string, left, right dataType) (dataType, error) {
+	switch {
+	case left == typeNull || right == typeNull:
+		return typeNull, nil
+	case left == typeTime && right == typeInterval && (op == "+" || op == "-"):
+		return typeTime, nil
+	case left == typeInterval && right == typeTime && op == "+":
+		return typeTime, nil
+	case left == typeTime && right == typeTime && op == "-":
+		return typeInterval, nil
+	case isNumeric(left) && isNumeric(right):
+		switch {
+		case left == typeFloat || right == typeFloat:
+			return typeFloat, nil
+		case left == typeUint && right == typeUint:
+			return typeUint, nil
+		}
+		return typeInt, nil
+	}
+	return typeNull, fmt.Errorf("Error during planning: Cannot coerce arithmetic expression %s %s %s to valid types", typeName(left), op, typeName(right))
+}
+
+// functionType 推断函数调用结果的类型
+func functionType(f *funcCall, args []dataType) (dataType, error) {
+	arg := typeNull
+	if len(args) > 0 {
+		arg = args[0]
+	}
+	switch f.name {
+	case "count":
+		return typeInt, nil
+	case "sum":
+		if arg == typeFloat || arg == typeInt || arg == typeUint || arg == typeNull {
+			return arg, nil
+		}
+	case "avg", "mean":
+		if isNumeric(arg) || arg == typeNull {
+			return typeFloat, nil
+		}
+	case "min", "max":
+		return arg, nil
+	case "approx_percentile_cont":
+		if isNumeric(arg) {
+			return arg, nil
+		}
+	case "array_agg":
+		if arg&listFlag == 0 && arg != typeStruct {
+			return arg | listFlag, nil
+		}
+	case "struct":
+		return typeStruct, nil
+	case "now":
+		return typeTime, nil
+	case "version", "lower", "upper":
+		return typeString, nil
+	case "array_to_string":
+		if arg&listFlag != 0 || arg == typeNull {
+			return typeString, nil
+		}
+	case "array_has":
+		if arg&listFlag != 0 || arg == typeNull {
+			return typeBool, nil
+		}
+	default:
+		return typeNull, fmt.Errorf("Error during planning: Invalid function '%s'", f.name)
+	}
+	return typeNull, fmt.Errorf("Error during planning: function %s does not support %s", f.name, typeName(arg))
+}
+
+func isNumeric(t dataType) bool {
+	return t == typeInt || t == typeUint || t == typeFloat
+}
+
+// typeName 返回类型在错误信息中的名称
+func typeName(t dataType) string {
+	if t&listFlag != 0 {
+		return "List(" + typeName(t&^listFlag) + ")"
+	}
+	switch t {
+	case typeString:
+		return "Utf8"
+	case typeInt:
+		return "Int64"
+	case typeUint:
+		return "UInt64"
+	case typeFloat:
+		return "Float64"
+	case typeBool:
+		return "Boolean"
+	case typeTime:
+		return "Timestamp(Nanosecond, None)"
+	case typeInterval:
+		return "Interval(MonthDayNano)"
+	case typeStruct:
+		return "Struct"
+	}
+	return "Null"
+}
+
+// eval 计算表达式的值，NULL 表示为 nil
+func (q *query) eval(e expr, ctx env) (any, error) {
+	switch x := e.(type) {
+	case *colRef:
+		return ctx.row[x.name], nil
+	case *literal:
+		return x.value, nil
+	case *unaryExpr:
+		v, err := q.eval(x.x, ctx)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		if x.op == "NOT" {
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("Error during planning: Cannot apply NOT to %T", v)
+			}
+			return !b, nil
+		}
+		return arithmetic("*", v, int64(-1))
+	case *binaryExpr:
+		return q.evalBinary(x, ctx)
+	case *inExpr:
+		v, err := q.eval(x.x, ctx)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		found, sawNull := false, false
+		for _, item := range x.list {
+			w, err := q.eval(item, ctx)
+			if err != nil {
+				return nil, err
+			}
+			if w == nil {
+				sawNull = true
+				continue
+			}
+			c, err := compare(v, w)
+			if err != nil {
+				return nil, err
+			}
+			if c == 0 {
+				found = true
+				break
+			}
+		}
+		if !found && sawNull {
+			return nil, nil
+		}
+		return found != x.not, nil
+	case *isNullExpr:
+		v, err := q.eval(x.x, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return (v == nil) != x.not, nil
+	case *betweenExpr:
+		and := &binaryExpr{op: "AND",
+			left:  &binaryExpr{op: ">=", left: x.x, right: x.low},
+			right: &binaryExpr{op: "<=", left: x.x, right: x.high},
+		}
+		v, err := q.evalBinary(and, ctx)
+		if err != nil || v == nil || !x.not {
+			return v, err
+		}
+		return !v.(bool), nil
+	case *likeExpr:
+		v, err := q.eval(x.x, ctx)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		pattern, err := q.eval(x.pattern, ctx)
+		if err != nil || pattern == nil {
+			return nil, err
+		}
+		s, ok1 := v.(string)
+		p, ok2 := pattern.(string)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("Error during planning: LIKE requires string operands")
+		}
+		return likeRegexp(p).MatchString(s) != x.not, nil
+	case *funcCall:
+		return q.evalCall(x, ctx)
+	}
+	return nil, fmt.Errorf("unsupported expression %s", e)
+}
+
+func (q *query) evalBinary(x *binaryExpr, ctx env) (any, error) {
+	left, err := q.eval(x.left, ctx)
+	if err != nil {
+		return nil, err
+	}
+	right, err := q.eval(x.right, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x.op {
+	case "AND", "OR":
+		l, lok := left.(bool)
+		r, rok := right.(bool)
+		if (left != nil && !lok) || (right != nil && !rok) {
+			return nil, fmt.Errorf("Error during planning: %s requires boolean operands", x.op)
+		}
+		if x.op == "AND" {
+			if (lok && !l) || (rok && !r) {
+				return false, nil
+			}
+			if left == nil || right == nil {
+				return nil, nil
+			}
+			return true, nil
+		}
+		if (lok && l) || (rok && r) {
+			return true, nil
+		}
+		if left == nil || right == nil {
+			return nil, nil
+		}
+		return false, nil
+	}
+
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	switch x.op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		c, err := compare(left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch x.op {
+		case "=":
+			return c == 0, nil
+		case "!=":
+			return c != 0, nil
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	}
+	return arithmetic(x.op, left, right)
+}
+
+func (q *query) evalCall(f *funcCall, ctx env) (any, error) {
+	if isAggregate(f.name) {
+		return q.aggregate(f, ctx)
+	}
+
+	args := make([]any, len(f.args))
+	for i, arg := range f.args {
+		v, err := q.eval(arg, ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	switch f.name {
+	case "struct":
+		s := structValue{values: args}
+		for i := range args {
+			s.names = append(s.names, fmt.Sprintf("c%d", i))
+		}
+		return s, nil
+	case "now":
+		return q.now, nil
+	case "version":
+		return "influxdb3test", nil
+	case "lower", "upper":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("Error during planning: %s expects 1 argument", f.name)
+		}
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, nil
+		}
+		if f.name == "lower" {
+			return strings.ToLower(s), nil
+		}
+		return strings.ToUpper(s), nil
+	case "array_to_string":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("Error during planning: %s expects 2 arguments", f.name)
+		}
+		list, ok := args[0].([]any)
+		sep, _ := args[1].(string)
+		if !ok {
+			return nil, nil
+		}
+		values := make([]string, 0, len(list))
+		for _, v := range list {
+			if v != nil {
+				values = append(values, fmt.Sprint(v))
+			}
+		}
+		return strings.Join(values, sep), nil
+	case "array_has":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("Error during planning: %s expects 2 arguments", f.name)
+		}
+		list, ok := args[0].([]any)
+		if !ok || args[1] == nil {
+			return nil, nil
+		}
+		return slices.Contains(list, args[1]), nil
+	}
+	return nil, fmt.Errorf("Error during planning: Invalid function '%s'", f.name)
+}
+
+// aggregate 在当前分组上计算聚合函数
+func (q *query) aggregate(f *funcCall, ctx env) (any, error) {
+	if f.star {
+		if f.name != "count" {
+			return nil, fmt.Errorf("Error during planning: %s(*) is not supported", f.name)
+		}
+		return int64(len(ctx.group)), nil
+	}
+	if f.name == "approx_percentile_cont" {
+		return q.percentile(f, ctx)
+	}
+	if len(f.args) != 1 {
+		return nil, fmt.Errorf("Error during planning: %s expects 1 argument", f.name)
+	}
+
+	var values []any
+	seen := map[string]bool{}
+	for _, row := range ctx.group {
+		v, err := q.eval(f.args[0], env{row: row})
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		if f.distinct {
+			key := fmt.Sprintf("%T:%v", v, v)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		values = append(values, v)
+	}
+
+	if f.name == "count" {
+		return int64(len(values)), nil
+	}
+	if f.name == "array_agg" {
+		// 与服务端不同，NULL 值不会放入列表
+		return values, nil
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	switch f.name {
+	case "sum", "avg", "mean":
+		var sum any = values[0]
+		for _, v := range values[1:] {
+			var err error
+			if sum, err = arithmetic("+", sum, v); err != nil {
+				return nil, err
+			}
+		}
+		if f.name == "sum" {
+			return sum, nil
+		}
+		return toFloat(sum) / float64(len(values)), nil
+	}
+
+	best := values[0]
+	for _, v := range values[1:] {
+		c, err := compare(v, best)
+		if err != nil {
+			return nil, err
+		}
+		if (f.name == "min" && c < 0) || (f.name == "max" && c > 0) {
+			best = v
+		}
+	}
+	return best, nil
+}
+
+// percentile 计算 approx_percentile_cont(x, p)，在排序后的值之间线性插值，结果与 x 的类型相同
+func (q *query) percentile(f *funcCall, ctx env) (any, error) {
+	if len(f.args) != 2 {
+		return nil, fmt.Errorf("Error during planning: %s expects 2 arguments", f.name)
+	}
+	p, err := q.eval(f.args[1], env{})
+	if err != nil {
+		return nil, err
+	}
+	if pf := toFloat(p); !(pf >= 0 && pf <= 1) {
+		return nil, fmt.Errorf("Error during planning: Percentile value must be between 0.0 and 1.0 inclusive, %v is invalid", p)
+	}
+
+	var values []float64
+	var sample any
+	for _, row := range ctx.group {
+		v, err := q.eval(f.args[0], env{row: row})
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			values = append(values, toFloat(v))
+			sample = v
+		}
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	sort.Float64s(values)
+
+	rank := toFloat(p) * float64(len(values)-1)
+	lower := int(math.Floor(rank))
+	result := values[lower]
+	if upper := int(math.Ceil(rank)); upper != lower {
+		result += (values[upper] - values[lower]) * (rank - float64(lower))
+	}
+	switch sample.(type) {
+	case int64:
+		return int64(result), nil
+	case uint64:
+		return uint64(result), nil
+	}
+	return result, nil
+}
+
+// compare 比较两个非空值，返回 -1、0 或 1
+// 数值之间可以比较，时间可以与时间字符串比较
+func compare(a, b any) (int, error) {
+	switch x := a.(type) {
+	case string:
+		switch y := b.(type) {
+		case string:
+			return strings.Compare(x, y), nil
+		case time.Time:
+			t, err := parseTime(x)
+			if err != nil {
+				return 0, fmt.Errorf("Arrow error: Cast error: %v", err)
+			}
+			return compareInt(t.UnixNano(), y.UnixNano()), nil
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, nil
+			case !x:
+				return -1, nil
+			}
+			return 1, nil
+		}
+	case time.Time:
+		switch y := b.(type) {
+		case time.Time:
+			return compareInt(x.UnixNano(), y.UnixNano()), nil
+		case string:
+			c, err := compare(y, x)
+			return -c, err
+		}
+	case time.Duration:
+		if y, ok := b.(time.Duration); ok {
+			return compareInt(int64(x), int64(y)), nil
+		}
+	case int64, uint64, float64:
+		switch b.(type) {
+		case int64, uint64, float64:
+			return compareNumbers(a, b), nil
+		}
+	}
+	return 0, fmt.Errorf("Error during planning: Cannot compare %s with %s", typeName(typeOfValue(a)), typeName(typeOfValue(b)))
+}
+
+func compareInt(x, y int64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// compareNumbers 比较两个数值，整数之间按精确值比较
+func compareNumbers(a, b any) int {
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return compareInt(x, y)
+		case uint64:
+			if x < 0 || y > math.MaxInt64 {
+				return -1
+			}
+			return compareInt(x, int64(y))
+		}
+	case uint64:
+		switch y := b.(type) {
+		case uint64:
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		case int64:
+			return -compareNumbers(y, x)
+		}
+	}
+	fx, fy := toFloat(a), toFloat(b)
+	switch {
+	case fx < fy:
+		return -1
+	case fx > fy:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v any) float64 {
+	switch x := v.(type) {
+	case int64:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case float64:
+		return x
+	}
+	return math.NaN()
+}
+
+// arithmetic 计算两个非空值的算术运算
+func arithmetic(op string, a, b any) (any, error) {
+	switch x := a.(type) {
+	case time.Time:
+		switch y := b.(type) {
+		case time.Duration:
+			if op == "+" {
+				return x.Add(y), nil
+			}
+			if op == "-" {
+				return x.Add(-y), nil
+			}
+		case time.Time:
+			if op == "-" {
+				return x.Sub(y), nil
+			}
+		}
+	case time.Duration:
+		if y, ok := b.(time.Time); ok && op == "+" {
+			return y.Add(x), nil
+		}
+		if y, ok := b.(int64); ok && op == "*" {
+			return x * time.Duration(y), nil
+		}
+	}
+
+	if !isNumeric(typeOfValue(a)) || !isNumeric(typeOfValue(b)) {
+		return nil, fmt.Errorf("Error during planning: Cannot coerce arithmetic expression %s %s %s to valid types",
+			typeName(typeOfValue(a)), op, typeName(typeOfValue(b)))
+	}
+
+	x, xok := a.(uint64)
+	y, yok := b.(uint64)
+	if xok && yok {
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		}
+		if y == 0 {
+			return nil, fmt.Errorf("Arrow error: Divide by zero error")
+		}
+		if op == "/" {
+			return x / y, nil
+		}
+		return x % y, nil
+	}
+
+	_, xf := a.(float64)
+	_, yf := b.(float64)
+	if xf || yf {
+		fx, fy := toFloat(a), toFloat(b)
+		switch op {
+		case "+":
+			return fx + fy, nil
+		case "-":
+			return fx - fy, nil
+		case "*":
+			return fx * fy, nil
+		case "/":
+			return fx / fy, nil
+		}
+		return math.Mod(fx, fy), nil
+	}
+
+	ix, iy := int64(toFloat(a)), int64(toFloat(b))
+	if v, ok := a.(int64); ok {
+		ix = v
+	}
+	if v, ok := b.(int64); ok {
+		iy = v
+	}
+	switch op {
+	case "+":
+		return ix + iy, nil
+	case "-":
+		return ix - iy, nil
+	case "*":
+		return ix * iy, nil
+	}
+	if iy == 0 {
+		return nil, fmt.Errorf("Arrow error: Divide by zero error")
+	}
+	if op == "/" {
+		return ix / iy, nil
+	}
+	return ix % iy, nil
+}
+
+// coerce 将值转换为输出列的类型
+func coerce(v any, typ dataType) any {
+	switch typ {
+	case typeFloat:
+		switch v.(type) {
+		case int64, uint64:
+			return toFloat(v)
+		}
+	case typeInt:
+		if u, ok := v.(uint64); ok {
+			return int64(u)
+		}
+	}
+	return v
+}
+
+// likeRegexp 将 LIKE 模式转换为正则表达式
+func likeRegexp(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
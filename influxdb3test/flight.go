@@ -0,0 +1,285 @@
+package influxdb3test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ticket 客户端在 DoGet 请求中发送的查询
+type ticket struct {
+	Database  string `json:"database"`
+	SQLQuery  string `json:"sql_query"`
+	QueryType string `json:"query_type"`
+}
+
+// flightServer 实现 Arrow Flight 的 DoGet 接口
+type flightServer struct {
+	flight.BaseFlightServer
+	server *Server
+}
+
+// DoGet 执行查询并以 Arrow 记录批次返回结果
+func (f *flightServer) DoGet(t *flight.Ticket, stream flight.FlightService_DoGetServer) error {
+	if err := f.server.authorizeGRPC(stream.Context()); err != nil {
+		return err
+	}
+
+	var tk ticket
+	if err := json.Unmarshal(t.GetTicket(), &tk); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid ticket: %v", err)
+	}
+	if tk.QueryType == "" {
+		tk.QueryType = "sql"
+	}
+
+	res, err := f.server.query(tk)
+	if err != nil {
+		return err
+	}
+
+	f.server.mu.Lock()
+	f.server.active++
+	f.server.mu.Unlock()
+	defer func() {
+		f.server.mu.Lock()
+		f.server.active--
+		f.server.mu.Unlock()
+	}()
+
+	schema := resultSchema(res, f.server.dictionaryTags, timeUnit(f.server.timestampUnit))
+	writer := flight.NewRecordWriter(stream, ipc.WithSchema(schema))
+	defer writer.Close()
+
+	size := f.server.batchSize
+	if size <= 0 {
+		size = len(res.rows)
+	}
+	for start := 0; start < len(res.rows); start += size {
+		if start > 0 && f.server.batchInterval > 0 {
+			select {
+			case <-stream.Context().Done():
+				return stream.Context().Err()
+			case <-time.After(f.server.batchInterval):
+			}
+		}
+
+		record := buildRecord(schema, res.rows[start:min(start+size, len(res.rows))])
+		err := writer.Write(record)
+		record.Release()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authorizeGRPC 校验 gRPC 请求中的认证信息
+func (s *Server) authorizeGRPC(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if s.authorized(value) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "Unauthenticated")
+}
+
+// authorized 判断认证头是否有效，支持 Bearer 和 Token 两种写法
+func (s *Server) authorized(header string) bool {
+	scheme, token, ok := strings.Cut(header, " ")
+	return ok && (strings.EqualFold(scheme, "Bearer") || strings.EqualFold(scheme, "Token")) && token == s.Token
+}
+
+// query 记录并执行查询
+func (s *Server) query(tk ticket) (*result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queries = append(s.queries, Query{Database: tk.Database, SQL: tk.SQLQuery, QueryType: tk.QueryType})
+	db, ok := s.databases[tk.Database]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "database not found: %s", tk.Database)
+	}
+	if tk.QueryType != "sql" {
+		return nil, status.Errorf(codes.Unimplemented, "query type %s is not supported by influxdb3test", tk.QueryType)
+	}
+
+	res, err := execute(db, tk.SQLQuery, time.Now().UTC())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return res, nil
+}
+
+// resultSchema 返回查询结果的 Arrow schema
+// 直接引用表中的列时与服务端一样带有 iox::column::type 元数据，dictionaryTags 为 true 时 tag 列使用字典编码，
+// 时间戳列使用 unit 指定的单位
+func resultSchema(res *result, dictionaryTags bool, unit arrow.TimeUnit) *arrow.Schema {
+	fields := make([]arrow.Field, len(res.columns))
+	for i, c := range res.columns {
+		field := arrow.Field{Name: c.name, Type: arrowType(c.typ), Nullable: true}
+		switch c.typ {
+		case typeStruct:
+			field.Type = structType(res, i)
+		case typeTime:
+			field.Type = &arrow.TimestampType{Unit: unit}
+		}
+		if c.source != nil && res.iox {
+			field.Metadata = arrow.NewMetadata([]string{"iox::column::type"}, []string{c.source.ioxType()})
+			field.Nullable = c.source.kind != kindTime
+			if dictionaryTags && c.source.kind == kindTag {
+				field.Type = &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.String}
+			}
+		}
+		fields[i] = field
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+// structType 按第 i 列中第一个非空的值确定 struct 的字段和类型
+func structType(res *result, i int) arrow.DataType {
+	for _, row := range res.rows {
+		s, ok := row[i].(structValue)
+		if !ok {
+			continue
+		}
+		fields := make([]arrow.Field, len(s.names))
+		for j, name := range s.names {
+			fields[j] = arrow.Field{Name: name, Type: arrowType(typeOfValue(s.values[j])), Nullable: true}
+		}
+		return arrow.StructOf(fields...)
+	}
+	return arrow.StructOf()
+}
+
+// arrowType 返回类型对应的 Arrow 类型
+func arrowType(t dataType) arrow.DataType {
+	if t&listFlag != 0 {
+		return arrow.ListOf(arrowType(t &^ listFlag))
+	}
+	switch t {
+	case typeString:
+		return arrow.BinaryTypes.String
+	case typeInt:
+		return arrow.PrimitiveTypes.Int64
+	case typeUint:
+		return arrow.PrimitiveTypes.Uint64
+	case typeFloat:
+		return arrow.PrimitiveTypes.Float64
+	case typeBool:
+		return arrow.FixedWidthTypes.Boolean
+	case typeTime:
+		return &arrow.TimestampType{Unit: arrow.Nanosecond}
+	case typeInterval:
+		return arrow.FixedWidthTypes.Duration_ns
+	}
+	return arrow.Null
+}
+
+// timeUnit 将时间单位转换为 Arrow 的时间戳单位，不是秒、毫秒或微秒时使用纳秒
+func timeUnit(d time.Duration) arrow.TimeUnit {
+	switch d {
+	case time.Second:
+		return arrow.Second
+	case time.Millisecond:
+		return arrow.Millisecond
+	case time.Microsecond:
+		return arrow.Microsecond
+	}
+	return arrow.Nanosecond
+}
+
+// buildRecord 将查询结果中的行转换为 Arrow 记录批次
+func buildRecord(schema *arrow.Schema, rows [][]any) arrow.Record {
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer builder.Release()
+
+	for i := range schema.Fields() {
+		fb := builder.Field(i)
+		for _, row := range rows {
+			appendValue(fb, row[i])
+		}
+	}
+	return builder.NewRecord()
+}
+
+// appendValue 追加一个值，类型与列不一致的值按 NULL 处理
+func appendValue(b array.Builder, v any) {
+	if v == nil {
+		b.AppendNull()
+		return
+	}
+	switch b := b.(type) {
+	case *array.BinaryDictionaryBuilder:
+		if s, ok := v.(string); ok {
+			_ = b.AppendString(s)
+			return
+		}
+	case *array.ListBuilder:
+		if values, ok := v.([]any); ok {
+			b.Append(true)
+			for _, value := range values {
+				appendValue(b.ValueBuilder(), value)
+			}
+			return
+		}
+	case *array.StructBuilder:
+		if s, ok := v.(structValue); ok && len(s.values) == b.NumField() {
+			b.Append(true)
+			for i, value := range s.values {
+				appendValue(b.FieldBuilder(i), value)
+			}
+			return
+		}
+	case *array.StringBuilder:
+		if s, ok := v.(string); ok {
+			b.Append(s)
+			return
+		}
+		b.Append(fmt.Sprint(v))
+		return
+	case *array.Int64Builder:
+		if x, ok := v.(int64); ok {
+			b.Append(x)
+			return
+		}
+	case *array.Uint64Builder:
+		if x, ok := v.(uint64); ok {
+			b.Append(x)
+			return
+		}
+	case *array.Float64Builder:
+		if x, ok := v.(float64); ok {
+			b.Append(x)
+			return
+		}
+	case *array.BooleanBuilder:
+		if x, ok := v.(bool); ok {
+			b.Append(x)
+			return
+		}
+	case *array.TimestampBuilder:
+		if x, ok := v.(time.Time); ok {
+			b.AppendTime(x)
+			return
+		}
+	case *array.DurationBuilder:
+		if x, ok := v.(time.Duration); ok {
+			b.Append(arrow.Duration(x))
+			return
+		}
+	}
+	b.AppendNull()
+}
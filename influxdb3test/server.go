@@ -0,0 +1,329 @@
+// Package influxdb3test 提供用于测试的进程内 InfluxDB 3 服务端
+//
+// 服务端在本机随机端口上同时提供 Arrow Flight 查询接口和 HTTP 写入接口，
+// 写入的行协议保存在内存中，可以用简单的 SELECT 和 information_schema 查询读取。
+// 测试可以通过 Writes 和 Queries 检查服务端收到的写入和查询：
+//
+//	srv := influxdb3test.NewServer()
+//	defer srv.Close()
+//	db, _ := gorm.Open(influxdb3gorm.New(srv.Config()), &gorm.Config{})
+package influxdb3test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/influxdata/line-protocol/v2/lineprotocol"
+	"github.com/xiabin827/influxdb3-gorm-driver/dialector"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+)
+
+const (
+	// DefaultDatabase 未指定时使用的数据库名称
+	DefaultDatabase = "test"
+	// DefaultToken 未指定时使用的认证令牌
+	DefaultToken = "influxdb3test-token"
+)
+
+// Options 服务端配置
+type Options struct {
+	Database string // 预先创建的数据库，默认为 DefaultDatabase
+	Token    string // 认证令牌，默认为 DefaultToken
+	// DictionaryTags 为 true 时查询结果中的 tag 列以 Dictionary(Int32, Utf8) 返回，
+	// 用于测试字典编码的结果；默认与 Flight 接口一致，tag 列为普通的 Utf8
+	DictionaryTags bool
+	// TimestampUnit 查询结果中时间戳列的单位，如 time.Millisecond 返回 Timestamp(ms)；
+	// 为零时与服务端一致使用纳秒
+	TimestampUnit time.Duration
+	// BatchSize 每个记录批次的最大行数，为零时全部结果在一个批次中返回
+	BatchSize int
+	// BatchInterval 发送两个记录批次之间等待的时间，客户端取消查询时立即结束，
+	// 配合 ActiveQueries 检查提前结束读取的客户端是否取消了数据流
+	BatchInterval time.Duration
+}
+
+// Write 服务端收到的一次写入请求
+type Write struct {
+	Database      string
+	Precision     lineprotocol.Precision
+	AcceptPartial bool   // 是否允许部分写入，v2 接口总是允许
+	Body          string // 原始的行协议
+}
+
+// Query 服务端收到的一次查询
+type Query struct {
+	Database  string
+	SQL       string
+	QueryType string // sql 或 influxql
+}
+
+// Server 进程内的 InfluxDB 3 服务端
+type Server struct {
+	URL      string // 服务端地址，形如 http://127.0.0.1:port
+	Database string
+	Token    string
+
+	listener       net.Listener
+	http           *http.Server
+	grpc           *grpc.Server
+	dictionaryTags bool
+	timestampUnit  time.Duration
+	batchSize      int
+	batchInterval  time.Duration
+
+	mu        sync.Mutex
+	databases map[string]*database
+	writes    []Write
+	queries   []Query
+	active    int
+}
+
+// NewServer 启动服务端，使用完毕后需要调用 Close
+func NewServer(opts ...Options) *Server {
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Database == "" {
+		opt.Database = DefaultDatabase
+	}
+	if opt.Token == "" {
+		opt.Token = DefaultToken
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("influxdb3test: 监听端口失败: %v", err))
+	}
+
+	s := &Server{
+		URL:            "http://" + listener.Addr().String(),
+		Database:       opt.Database,
+		Token:          opt.Token,
+		listener:       listener,
+		grpc:           grpc.NewServer(),
+		dictionaryTags: opt.DictionaryTags,
+		timestampUnit:  opt.TimestampUnit,
+		batchSize:      opt.BatchSize,
+		batchInterval:  opt.BatchInterval,
+		databases:      map[string]*database{opt.Database: newDatabase()},
+	}
+	flight.RegisterFlightServiceServer(s.grpc, &flightServer{server: s})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v2/write", s.handleWriteV2)
+	mux.HandleFunc("POST /api/v3/write_lp", s.handleWriteV3)
+	mux.HandleFunc("DELETE /api/v3/configure/table", s.handleDeleteTable)
+	mux.HandleFunc("POST /api/v3/configure/last_cache", s.handleCreateLastCache)
+	mux.HandleFunc("DELETE /api/v3/configure/last_cache", s.handleDeleteLastCache)
+	mux.HandleFunc("POST /api/v3/configure/distinct_cache", s.handleCreateDistinctCache)
+	mux.HandleFunc("DELETE /api/v3/configure/distinct_cache", s.handleDeleteDistinctCache)
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "OK")
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not found")
+	})
+
+	// gRPC 和 HTTP 共用同一个端口，客户端使用不加密的 HTTP/2 发送 Flight 请求
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			s.grpc.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.Path != "/health" && !s.authorized(r.Header.Get("Authorization")) {
+			writeError(w, http.StatusUnauthorized, "the request was not authenticated")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+	s.http = &http.Server{Handler: h2c.NewHandler(handler, &http2.Server{})}
+	go func() { _ = s.http.Serve(listener) }()
+	return s
+}
+
+// Close 关闭服务端
+func (s *Server) Close() {
+	_ = s.http.Close()
+	s.grpc.Stop()
+}
+
+// Config 返回连接到服务端的驱动配置
+func (s *Server) Config() dialector.Config {
+	return dialector.Config{
+		Host:     s.URL,
+		Token:    s.Token,
+		Database: s.Database,
+	}
+}
+
+// Writes 返回服务端收到的全部写入请求
+func (s *Server) Writes() []Write {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Write(nil), s.writes...)
+}
+
+// Queries 返回服务端收到的全部查询
+func (s *Server) Queries() []Query {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Query(nil), s.queries...)
+}
+
+// ActiveQueries 返回仍在发送结果的查询数量
+func (s *Server) ActiveQueries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active
+}
+
+// Reset 清空全部数据以及记录的写入和查询，只保留默认数据库
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.databases = map[string]*database{s.Database: newDatabase()}
+	s.writes, s.queries = nil, nil
+}
+
+// WriteLineProtocol 直接向默认数据库写入纳秒精度的行协议，用于准备测试数据
+// 任何一行被拒绝时都不会写入，也不会记录到 Writes 中
+func (s *Server) WriteLineProtocol(data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if errs := s.databases[s.Database].write([]byte(data), lineprotocol.Nanosecond, false); len(errs) > 0 {
+		return fmt.Errorf("influxdb3test: 第 %d 行被拒绝: %s", errs[0].LineNumber, errs[0].ErrorMessage)
+	}
+	return nil
+}
+
+// Lines 以纳秒精度的行协议返回默认数据库中表的全部数据点，按时间戳排序
+func (s *Server) Lines(table string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.databases[s.Database].tables[table]
+	if !ok {
+		return nil
+	}
+	var lines []string
+	for _, p := range t.sortedPoints() {
+		point := influxdb3.NewPointWithMeasurement(table).SetTimestamp(time.Unix(0, p.time))
+		for name, value := range p.tags {
+			point.SetTag(name, value)
+		}
+		for name, value := range p.fields {
+			point.SetField(name, value)
+		}
+		data, err := dialector.EncodePoints([]*influxdb3.Point{point})
+		if err != nil {
+			panic(fmt.Sprintf("influxdb3test: 编码数据点失败: %v", err))
+		}
+		lines = append(lines, strings.TrimSuffix(string(data), "\n"))
+	}
+	return lines
+}
+
+// handleWriteV2 处理 v2 兼容的写入接口，总是写入有效的行
+func (s *Server) handleWriteV2(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	name := query.Get("bucket")
+	if name == "" {
+		name = query.Get("db")
+	}
+	s.handleWrite(w, r, name, query.Get("precision"), true)
+}
+
+// handleWriteV3 处理 v3 写入接口，accept_partial=false 时有任何一行被拒绝都不会写入
+func (s *Server) handleWriteV3(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	acceptPartial := true
+	if v := query.Get("accept_partial"); v != "" {
+		var err error
+		if acceptPartial, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid accept_partial: %s", v))
+			return
+		}
+	}
+	s.handleWrite(w, r, query.Get("db"), query.Get("precision"), acceptPartial)
+}
+
+func (s *Server) handleWrite(w http.ResponseWriter, r *http.Request, name, precisionParam string, acceptPartial bool) {
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "missing database name")
+		return
+	}
+	precision, err := parsePrecision(precisionParam)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid precision: %s", precisionParam))
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.writes = append(s.writes, Write{Database: name, Precision: precision, AcceptPartial: acceptPartial, Body: string(body)})
+	db, ok := s.databases[name]
+	if !ok {
+		// 与服务端一致，写入时自动创建数据库
+		db = newDatabase()
+		s.databases[name] = db
+	}
+	errs := db.write(body, precision, acceptPartial)
+	s.mu.Unlock()
+
+	if len(errs) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"error": "partial write of line protocol occurred",
+			"data":  errs,
+		})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeleteTable 删除整个表
+func (s *Server) handleDeleteTable(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	name, table := query.Get("db"), query.Get("table")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, ok := s.databases[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("database not found: %s", name))
+		return
+	}
+	if _, ok := db.tables[table]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("table not found: %s", table))
+		return
+	}
+	delete(db.tables, table)
+	db.lastCaches = slices.DeleteFunc(db.lastCaches, func(c *lastCache) bool { return c.table == table })
+	db.distinctCaches = slices.DeleteFunc(db.distinctCaches, func(c *distinctCache) bool { return c.table == table })
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeError 以 JSON 格式返回错误
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,823 @@
+package influxdb3test
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// tokenKind 词法单元的种类
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokQuoted
+	tokString
+	tokNumber
+	tokSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize 将查询语句拆分为词法单元
+func tokenize(sql string) ([]token, error) {
+	var tokens []token
+	runes := []rune(sql)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '\'' || r == '"':
+			var sb strings.Builder
+			j := i + 1
+			for {
+				if j >= len(runes) {
+					return nil, fmt.Errorf("unterminated quoted string at position %d", i)
+				}
+				if runes[j] == r {
+					if j+1 < len(runes) && runes[j+1] == r {
+						sb.WriteRune(r)
+						j += 2
+						continue
+					}
+					break
+				}
+				sb.WriteRune(runes[j])
+				j++
+			}
+			kind := tokString
+			if r == '"' {
+				kind = tokQuoted
+			}
+			tokens = append(tokens, token{kind: kind, text: sb.String()})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || runes[j] == 'e' || runes[j] == 'E' ||
+				((runes[j] == '+' || runes[j] == '-') && (runes[j-1] == 'e' || runes[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '$') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: string(runes[i:j])})
+			i = j
+		case i+1 < len(runes) && isOperator(string(runes[i:i+2])):
+			tokens = append(tokens, token{kind: tokSymbol, text: string(runes[i : i+2])})
+			i += 2
+		case strings.ContainsRune(",()*.=<>+-/%;", r):
+			tokens = append(tokens, token{kind: tokSymbol, text: string(r)})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
+
+// isOperator 判断是否为两个字符的运算符
+func isOperator(s string) bool {
+	switch s {
+	case "<=", ">=", "<>", "!=", "::", "||":
+		return true
+	}
+	return false
+}
+
+// selectStmt 解析后的 SELECT 语句
+type selectStmt struct {
+	distinct bool
+	items    []selectItem
+	from     *tableRef
+	where    expr
+	groupBy  []expr
+	orderBy  []orderItem
+	limit    int64 // 小于 0 表示没有限制
+	offset   int64
+}
+
+type selectItem struct {
+	star  bool // SELECT * 或 SELECT t.*
+	expr  expr
+	alias string
+}
+
+type tableRef struct {
+	schema string
+	name   string
+	alias  string
+	args   []expr // 表函数的参数，如 last_cache('cpu', 'name')
+	call   bool   // 是否为表函数
+}
+
+type orderItem struct {
+	expr expr
+	desc bool
+}
+
+// expr 表达式
+type expr interface {
+	String() string
+}
+
+type colRef struct {
+	table string
+	name  string
+}
+
+type literal struct {
+	value any
+}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+}
+
+type unaryExpr struct {
+	op string
+	x  expr
+}
+
+type inExpr struct {
+	x    expr
+	list []expr
+	not  bool
+}
+
+type isNullExpr struct {
+	x   expr
+	not bool
+}
+
+type betweenExpr struct {
+	x, low, high expr
+	not          bool
+}
+
+type likeExpr struct {
+	x, pattern expr
+	not        bool
+}
+
+type funcCall struct {
+	name     string
+	args     []expr
+	star     bool
+	distinct bool
+}
+
+func (c *colRef) String() string { return c.name }
+
+func (l *literal) String() string {
+	switch v := l.value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "Utf8(\"" + v + "\")"
+	case time.Time:
+		return "TimestampNanosecond(" + strconv.FormatInt(v.UnixNano(), 10) + ")"
+	}
+	return fmt.Sprint(l.value)
+}
+
+func (b *binaryExpr) String() string {
+	return b.left.String() + " " + b.op + " " + b.right.String()
+}
+
+func (u *unaryExpr) String() string {
+	if u.op == "NOT" {
+		return "NOT " + u.x.String()
+	}
+	return "(" + u.op + u.x.String() + ")"
+}
+
+func (e *inExpr) String() string {
+	list := make([]string, len(e.list))
+	for i, item := range e.list {
+		list[i] = item.String()
+	}
+	op := " IN "
+	if e.not {
+		op = " NOT IN "
+	}
+	return e.x.String() + op + "(" + strings.Join(list, ", ") + ")"
+}
+
+func (e *isNullExpr) String() string {
+	if e.not {
+		return e.x.String() + " IS NOT NULL"
+	}
+	return e.x.String() + " IS NULL"
+}
+
+func (e *betweenExpr) String() string {
+	op := " BETWEEN "
+	if e.not {
+		op = " NOT BETWEEN "
+	}
+	return e.x.String() + op + e.low.String() + " AND " + e.high.String()
+}
+
+func (e *likeExpr) String() string {
+	op := " LIKE "
+	if e.not {
+		op = " NOT LIKE "
+	}
+	return e.x.String() + op + e.pattern.String()
+}
+
+func (f *funcCall) String() string {
+	if f.star {
+		return f.name + "(*)"
+	}
+	args := make([]string, len(f.args))
+	for i, arg := range f.args {
+		args[i] = arg.String()
+	}
+	prefix := ""
+	if f.distinct {
+		prefix = "DISTINCT "
+	}
+	return f.name + "(" + prefix + strings.Join(args, ", ") + ")"
+}
+
+// reservedWords 不能作为省略 AS 的别名使用的关键字
+var reservedWords = map[string]bool{
+	"FROM": true, "WHERE": true, "GROUP": true, "ORDER": true, "LIMIT": true, "OFFSET": true,
+	"AND": true, "OR": true, "NOT": true, "AS": true, "ON": true, "BY": true, "ASC": true, "DESC": true,
+	"IN": true, "IS": true, "BETWEEN": true, "LIKE": true, "NULLS": true, "HAVING": true,
+}
+
+// parser 递归下降的 SELECT 语句解析器
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parseSelect 解析一条 SELECT 语句
+func parseSelect(sql string) (*selectStmt, error) {
+	tokens, err := tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	stmt, err := p.selectStmt()
+	if err != nil {
+		return nil, err
+	}
+	p.acceptSymbol(";")
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("unexpected token %q", p.peek().text)
+	}
+	return stmt, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// isKeyword 判断当前词法单元是否为指定的关键字
+func (p *parser) isKeyword(words ...string) bool {
+	t := p.peek()
+	if t.kind != tokIdent {
+		return false
+	}
+	for _, word := range words {
+		if strings.EqualFold(t.text, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) acceptKeyword(word string) bool {
+	if p.isKeyword(word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(word string) error {
+	if !p.acceptKeyword(word) {
+		return fmt.Errorf("expected %s, found %q", word, p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) acceptSymbol(symbol string) bool {
+	if t := p.peek(); t.kind == tokSymbol && t.text == symbol {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectSymbol(symbol string) error {
+	if !p.acceptSymbol(symbol) {
+		return fmt.Errorf("expected %s, found %q", symbol, p.peek().text)
+	}
+	return nil
+}
+
+// identifier 解析标识符，未加引号的标识符转换为小写
+func (p *parser) identifier() (string, error) {
+	t := p.next()
+	switch t.kind {
+	case tokQuoted:
+		return t.text, nil
+	case tokIdent:
+		return strings.ToLower(t.text), nil
+	}
+	return "", fmt.Errorf("expected identifier, found %q", t.text)
+}
+
+func (p *parser) selectStmt() (*selectStmt, error) {
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	stmt := &selectStmt{limit: -1}
+	stmt.distinct = p.acceptKeyword("DISTINCT")
+
+	for {
+		item, err := p.selectItem()
+		if err != nil {
+			return nil, err
+		}
+		stmt.items = append(stmt.items, item)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+
+	if p.acceptKeyword("FROM") {
+		ref, err := p.tableRef()
+		if err != nil {
+			return nil, err
+		}
+		stmt.from = ref
+	}
+
+	if p.acceptKeyword("WHERE") {
+		where, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		stmt.where = where
+	}
+
+	if p.acceptKeyword("GROUP") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			stmt.groupBy = append(stmt.groupBy, e)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+
+	if p.acceptKeyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			item := orderItem{expr: e}
+			if p.acceptKeyword("DESC") {
+				item.desc = true
+			} else {
+				p.acceptKeyword("ASC")
+			}
+			stmt.orderBy = append(stmt.orderBy, item)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+
+	for p.isKeyword("LIMIT", "OFFSET") {
+		keyword := strings.ToUpper(p.next().text)
+		t := p.next()
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if t.kind != tokNumber || err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid %s value %q", keyword, t.text)
+		}
+		if keyword == "LIMIT" {
+			stmt.limit = n
+		} else {
+			stmt.offset = n
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) selectItem() (selectItem, error) {
+	if p.acceptSymbol("*") {
+		return selectItem{star: true}, nil
+	}
+	// t.* 形式
+	if t := p.peek(); (t.kind == tokIdent || t.kind == tokQuoted) && p.pos+2 < len(p.tokens) &&
+		p.tokens[p.pos+1].text == "." && p.tokens[p.pos+2].text == "*" {
+		p.pos += 3
+		return selectItem{star: true}, nil
+	}
+
+	e, err := p.expr()
+	if err != nil {
+		return selectItem{}, err
+	}
+	item := selectItem{expr: e}
+	if p.acceptKeyword("AS") {
+		if item.alias, err = p.identifier(); err != nil {
+			return selectItem{}, err
+		}
+	} else if t := p.peek(); t.kind == tokQuoted || (t.kind == tokIdent && !reservedWords[strings.ToUpper(t.text)]) {
+		item.alias, _ = p.identifier()
+	}
+	return item, nil
+}
+
+func (p *parser) tableRef() (*tableRef, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	ref := &tableRef{name: name}
+	if p.acceptSymbol(".") {
+		ref.schema = name
+		if ref.name, err = p.identifier(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptSymbol("(") {
+		f, err := p.call(ref.name)
+		if err != nil {
+			return nil, err
+		}
+		ref.name, ref.args, ref.call = f.(*funcCall).name, f.(*funcCall).args, true
+	}
+	if p.acceptKeyword("AS") {
+		if ref.alias, err = p.identifier(); err != nil {
+			return nil, err
+		}
+	} else if t := p.peek(); t.kind == tokQuoted || (t.kind == tokIdent && !reservedWords[strings.ToUpper(t.text)]) {
+		ref.alias, _ = p.identifier()
+	}
+	return ref, nil
+}
+
+func (p *parser) expr() (expr, error) {
+	return p.or()
+}
+
+func (p *parser) or() (expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (expr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) not() (expr, error) {
+	if p.acceptKeyword("NOT") {
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "NOT", x: x}, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (expr, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind == tokSymbol {
+		switch t.text {
+		case "=", "!=", "<>", "<", "<=", ">", ">=":
+			p.pos++
+			right, err := p.additive()
+			if err != nil {
+				return nil, err
+			}
+			op := t.text
+			if op == "<>" {
+				op = "!="
+			}
+			return &binaryExpr{op: op, left: left, right: right}, nil
+		}
+	}
+
+	if p.acceptKeyword("IS") {
+		not := p.acceptKeyword("NOT")
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return &isNullExpr{x: left, not: not}, nil
+	}
+
+	not := false
+	if p.isKeyword("NOT") && p.pos+1 < len(p.tokens) {
+		switch strings.ToUpper(p.tokens[p.pos+1].text) {
+		case "IN", "BETWEEN", "LIKE":
+			p.pos++
+			not = true
+		}
+	}
+
+	switch {
+	case p.acceptKeyword("IN"):
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		var list []expr
+		for {
+			item, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		return &inExpr{x: left, list: list, not: not}, nil
+	case p.acceptKeyword("BETWEEN"):
+		low, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		return &betweenExpr{x: left, low: low, high: high, not: not}, nil
+	case p.acceptKeyword("LIKE"):
+		pattern, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		return &likeExpr{x: left, pattern: pattern, not: not}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) additive() (expr, error) {
+	left, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokSymbol || (t.text != "+" && t.text != "-") {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *parser) multiplicative() (expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokSymbol || (t.text != "*" && t.text != "/" && t.text != "%") {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *parser) unary() (expr, error) {
+	if p.acceptSymbol("-") {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if l, ok := x.(*literal); ok {
+			switch v := l.value.(type) {
+			case int64:
+				return &literal{value: -v}, nil
+			case float64:
+				return &literal{value: -v}, nil
+			}
+		}
+		return &unaryExpr{op: "-", x: x}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokNumber:
+		p.pos++
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &literal{value: i}, nil
+		}
+		if u, err := strconv.ParseUint(t.text, 10, 64); err == nil {
+			return &literal{value: u}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return &literal{value: f}, nil
+	case tokString:
+		p.pos++
+		return &literal{value: t.text}, nil
+	case tokSymbol:
+		if p.acceptSymbol("(") {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			return e, nil
+		}
+		return nil, fmt.Errorf("unexpected token %q", t.text)
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of statement")
+	}
+
+	if t.kind == tokIdent {
+		switch strings.ToUpper(t.text) {
+		case "NULL":
+			p.pos++
+			return &literal{value: nil}, nil
+		case "TRUE", "FALSE":
+			p.pos++
+			return &literal{value: strings.EqualFold(t.text, "TRUE")}, nil
+		case "TIMESTAMP":
+			if p.tokens[p.pos+1].kind == tokString {
+				p.pos++
+				ts, err := parseTime(p.next().text)
+				if err != nil {
+					return nil, err
+				}
+				return &literal{value: ts}, nil
+			}
+		case "INTERVAL":
+			if p.tokens[p.pos+1].kind == tokString {
+				p.pos++
+				d, err := parseInterval(p.next().text)
+				if err != nil {
+					return nil, err
+				}
+				return &literal{value: d}, nil
+			}
+		}
+	}
+
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	if p.acceptSymbol("(") {
+		return p.call(name)
+	}
+	if p.acceptSymbol(".") {
+		column, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		return &colRef{table: name, name: column}, nil
+	}
+	return &colRef{name: name}, nil
+}
+
+// call 解析函数调用的参数，函数名已经读取
+func (p *parser) call(name string) (expr, error) {
+	f := &funcCall{name: strings.ToLower(name)}
+	if p.acceptSymbol("*") {
+		f.star = true
+		return f, p.expectSymbol(")")
+	}
+	if p.acceptSymbol(")") {
+		return f, nil
+	}
+	f.distinct = p.acceptKeyword("DISTINCT")
+	for {
+		arg, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		f.args = append(f.args, arg)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	return f, p.expectSymbol(")")
+}
+
+// parseTime 解析时间字面量，支持 RFC3339 和省略时区的写法，省略时区时按 UTC 处理
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
+
+// intervalUnits INTERVAL 字面量支持的单位
+var intervalUnits = map[string]time.Duration{
+	"nanosecond": time.Nanosecond, "microsecond": time.Microsecond, "millisecond": time.Millisecond,
+	"second": time.Second, "minute": time.Minute, "hour": time.Hour, "day": 24 * time.Hour, "week": 7 * 24 * time.Hour,
+}
+
+// parseInterval 解析 '1 hour 30 minutes' 形式的时间间隔
+func parseInterval(s string) (time.Duration, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return 0, fmt.Errorf("invalid interval %q", s)
+	}
+	var d time.Duration
+	for i := 0; i < len(fields); i += 2 {
+		n, err := strconv.ParseInt(fields[i], 10, 64)
+		unit, ok := intervalUnits[strings.TrimSuffix(fields[i+1], "s")]
+		if err != nil || !ok {
+			return 0, fmt.Errorf("invalid interval %q", s)
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, nil
+}
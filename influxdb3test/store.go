@@ -0,0 +1,397 @@
+package influxdb3test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdata/line-protocol/v2/lineprotocol"
+)
+
+// columnKind 列的种类
+type columnKind int
+
+const (
+	kindField columnKind = iota
+	kindTag
+	kindTime
+)
+
+// dataType 列和表达式的值类型
+type dataType int
+
+const (
+	typeNull dataType = iota
+	typeString
+	typeInt
+	typeUint
+	typeFloat
+	typeBool
+	typeTime
+	typeInterval
+	typeStruct // struct 函数的结果，字段类型按结果中的值确定
+)
+
+// listFlag 与元素类型组合表示列表类型，如 typeFloat | listFlag 表示 List(Float64)
+const listFlag dataType = 1 << 4
+
+// structValue struct 函数返回的值，字段按参数的顺序命名为 c0、c1 ...
+type structValue struct {
+	names  []string
+	values []any
+}
+
+// column 表中的一列
+type column struct {
+	name string
+	kind columnKind
+	typ  dataType
+}
+
+// ioxType 返回列在 iox::column::type 元数据中的类型名称
+func (c *column) ioxType() string {
+	switch c.kind {
+	case kindTag:
+		return "iox::column_type::tag"
+	case kindTime:
+		return "iox::column_type::timestamp"
+	}
+	switch c.typ {
+	case typeInt:
+		return "iox::column_type::field::integer"
+	case typeUint:
+		return "iox::column_type::field::uinteger"
+	case typeFloat:
+		return "iox::column_type::field::float"
+	case typeBool:
+		return "iox::column_type::field::boolean"
+	}
+	return "iox::column_type::field::string"
+}
+
+// point 表中的一个数据点，measurement、tag 和时间戳相同的数据点会合并 field
+type point struct {
+	tags   map[string]string
+	fields map[string]any
+	time   int64
+}
+
+// table 一个 measurement 中的全部数据
+type table struct {
+	name    string
+	columns map[string]*column
+	points  map[string]*point // 按 tag 和时间戳索引
+}
+
+// database 一个数据库中的全部表
+type database struct {
+	tables         map[string]*table
+	lastCaches     []*lastCache
+	distinctCaches []*distinctCache
+}
+
+func newDatabase() *database {
+	return &database{tables: map[string]*table{}}
+}
+
+// sortedColumns 按名称排序返回表的列
+func (t *table) sortedColumns() []*column {
+	columns := make([]*column, 0, len(t.columns))
+	for _, c := range t.columns {
+		columns = append(columns, c)
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].name < columns[j].name })
+	return columns
+}
+
+// sortedPoints 按时间戳和 tag 排序返回表的数据点
+func (t *table) sortedPoints() []*point {
+	keys := make([]string, 0, len(t.points))
+	for key := range t.points {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		pi, pj := t.points[keys[i]], t.points[keys[j]]
+		if pi.time != pj.time {
+			return pi.time < pj.time
+		}
+		return keys[i] < keys[j]
+	})
+	points := make([]*point, len(keys))
+	for i, key := range keys {
+		points[i] = t.points[key]
+	}
+	return points
+}
+
+// seriesKey 返回数据点的索引键
+func seriesKey(tags map[string]string, ts int64) string {
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sb, "%q=%q,", name, tags[name])
+	}
+	fmt.Fprintf(&sb, "%d", ts)
+	return sb.String()
+}
+
+// entry 解析后的一行行协议
+type entry struct {
+	table  string
+	tags   map[string]string
+	fields map[string]any
+	time   int64
+}
+
+// LineError 被拒绝的一行，与服务端部分写入失败时返回的结构一致
+type LineError struct {
+	OriginalLine string `json:"original_line"`
+	LineNumber   int    `json:"line_number"`
+	ErrorMessage string `json:"error_message"`
+}
+
+// write 解析行协议并写入数据库，返回被拒绝的行
+// acceptPartial 为 false 时只要有一行被拒绝，整批数据都不会写入
+func (db *database) write(data []byte, precision lineprotocol.Precision, acceptPartial bool) []LineError {
+	now := time.Now()
+	var errs []LineError
+	var entries []*entry
+	pending := map[string]map[string]*column{}
+
+	for _, line := range splitLines(data) {
+		e, err := parseLine(line.text, precision, now)
+		if err == nil {
+			err = db.check(e, pending)
+		}
+		if err != nil {
+			errs = append(errs, LineError{OriginalLine: string(line.text), LineNumber: line.number, ErrorMessage: err.Error()})
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	if len(errs) > 0 && !acceptPartial {
+		return errs
+	}
+	for _, e := range entries {
+		db.apply(e)
+	}
+	return errs
+}
+
+// check 检查数据点的列类型与表中已有的列以及同一批次中先出现的列是否一致
+// 被拒绝的原因模仿服务端返回的英文错误信息
+func (db *database) check(e *entry, pending map[string]map[string]*column) error {
+	columns := pending[e.table]
+	if columns == nil {
+		columns = map[string]*column{}
+		pending[e.table] = columns
+	}
+	lookup := func(name string) *column {
+		if c, ok := columns[name]; ok {
+			return c
+		}
+		if t, ok := db.tables[e.table]; ok {
+			return t.columns[name]
+		}
+		return nil
+	}
+
+	added := map[string]*column{}
+	for name := range e.tags {
+		if name == "time" {
+			return fmt.Errorf("tag key 'time' is reserved")
+		}
+		if c := lookup(name); c != nil && c.kind != kindTag {
+			return fmt.Errorf("invalid column type for column '%s', expected %s, got iox::column_type::tag", name, c.ioxType())
+		}
+		added[name] = &column{name: name, kind: kindTag, typ: typeString}
+	}
+	for name, value := range e.fields {
+		if name == "time" {
+			return fmt.Errorf("field key 'time' is reserved")
+		}
+		if _, ok := e.tags[name]; ok {
+			return fmt.Errorf("column '%s' is both a tag and a field", name)
+		}
+		c := &column{name: name, kind: kindField, typ: typeOfValue(value)}
+		if existing := lookup(name); existing != nil && (existing.kind != kindField || existing.typ != c.typ) {
+			return fmt.Errorf("invalid column type for column '%s', expected %s, got %s", name, existing.ioxType(), c.ioxType())
+		}
+		added[name] = c
+	}
+	for name, c := range added {
+		columns[name] = c
+	}
+	return nil
+}
+
+// apply 将数据点写入表中，已有相同 tag 和时间戳的数据点时合并 field
+func (db *database) apply(e *entry) {
+	t, ok := db.tables[e.table]
+	if !ok {
+		t = &table{
+			name:    e.table,
+			columns: map[string]*column{"time": {name: "time", kind: kindTime, typ: typeTime}},
+			points:  map[string]*point{},
+		}
+		db.tables[e.table] = t
+	}
+	for name := range e.tags {
+		if _, ok := t.columns[name]; !ok {
+			t.columns[name] = &column{name: name, kind: kindTag, typ: typeString}
+		}
+	}
+	for name, value := range e.fields {
+		if _, ok := t.columns[name]; !ok {
+			t.columns[name] = &column{name: name, kind: kindField, typ: typeOfValue(value)}
+		}
+	}
+
+	key := seriesKey(e.tags, e.time)
+	p, ok := t.points[key]
+	if !ok {
+		p = &point{tags: e.tags, fields: map[string]any{}, time: e.time}
+		t.points[key] = p
+	}
+	for name, value := range e.fields {
+		p.fields[name] = value
+	}
+}
+
+// rawLine 请求体中的一行
+type rawLine struct {
+	number int
+	text   []byte
+}
+
+// splitLines 将请求体拆分为行，跳过空行和注释
+// 字符串 field 中可以包含换行符，只在双引号之外拆分
+func splitLines(data []byte) []rawLine {
+	var lines []rawLine
+	number, start := 1, 0
+	inFields, inString, escaped := false, false, false
+
+	flush := func(end int) {
+		text := []byte(strings.TrimSpace(string(data[start:end])))
+		if len(text) > 0 && text[0] != '#' {
+			lines = append(lines, rawLine{number: number, text: text})
+		}
+	}
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case inString:
+			if c == '"' {
+				inString = false
+			}
+		case c == ' ' && !inFields:
+			inFields = true
+		case c == '"' && inFields && i > 0 && data[i-1] == '=':
+			inString = true
+		case c == '\n':
+			flush(i)
+			number++
+			start = i + 1
+			inFields = false
+		}
+	}
+	if start < len(data) {
+		flush(len(data))
+	}
+	return lines
+}
+
+// parseLine 解析一行行协议
+func parseLine(text []byte, precision lineprotocol.Precision, now time.Time) (*entry, error) {
+	dec := lineprotocol.NewDecoderWithBytes(text)
+	if !dec.Next() {
+		return nil, fmt.Errorf("empty line")
+	}
+
+	measurement, err := dec.Measurement()
+	if err != nil {
+		return nil, err
+	}
+	e := &entry{table: string(measurement), tags: map[string]string{}, fields: map[string]any{}}
+
+	for {
+		key, value, err := dec.NextTag()
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			break
+		}
+		e.tags[string(key)] = string(value)
+	}
+	for {
+		key, value, err := dec.NextField()
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			break
+		}
+		e.fields[string(key)] = value.Interface()
+	}
+
+	ts, err := dec.Time(precision, now)
+	if err != nil {
+		return nil, err
+	}
+	if dec.Next() {
+		return nil, fmt.Errorf("unexpected data after timestamp")
+	}
+	e.time = ts.UnixNano()
+	return e, nil
+}
+
+// typeOfValue 返回 field 值的类型
+func typeOfValue(value any) dataType {
+	switch value.(type) {
+	case int64:
+		return typeInt
+	case uint64:
+		return typeUint
+	case float64:
+		return typeFloat
+	case bool:
+		return typeBool
+	case time.Time:
+		return typeTime
+	case time.Duration:
+		return typeInterval
+	case structValue:
+		return typeStruct
+	case nil:
+		return typeNull
+	}
+	return typeString
+}
+
+// parsePrecision 解析写入接口的 precision 参数，同时支持 v2 和 v3 接口的写法
+func parsePrecision(s string) (lineprotocol.Precision, error) {
+	switch s {
+	case "", "ns", "n", "nanosecond", "auto":
+		return lineprotocol.Nanosecond, nil
+	case "us", "u", "µs", "microsecond":
+		return lineprotocol.Microsecond, nil
+	case "ms", "millisecond":
+		return lineprotocol.Millisecond, nil
+	case "s", "second":
+		return lineprotocol.Second, nil
+	}
+	return lineprotocol.Nanosecond, fmt.Errorf("不支持的精度 %q", s)
+}
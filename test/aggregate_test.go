@@ -0,0 +1,144 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// aggregateData 测试聚合用的数据，bytes 为无符号整数
+const aggregateData = `readings,sensor=a value=1.5,count=1i,bytes=10u 1000000000
+readings,sensor=a value=2.5,count=2i,bytes=20u 2000000000
+readings,sensor=b value=4,count=3i,bytes=18446744073709551615u 3000000000
+readings,sensor=b value=8,count=4i 4000000000
+`
+
+func TestAggregateCount(t *testing.T) {
+	_, db := openServer(t, serverOptions{Data: aggregateData})
+
+	var count int64
+	if err := db.Model(&Reading{}).Count(&count).Error; err != nil {
+		t.Fatalf("Count 失败: %v", err)
+	}
+	if count != 4 {
+		t.Errorf("Count 结果不正确: %d", count)
+	}
+
+	// 列名中没有 count 时同样得到整数
+	var n int64
+	if err := db.Model(&Reading{}).Select("count(bytes) AS n").Scan(&n).Error; err != nil {
+		t.Fatalf("count 扫描失败: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("count(bytes) 结果不正确: %d", n)
+	}
+}
+
+func TestAggregateSum(t *testing.T) {
+	_, db := openServer(t, serverOptions{Data: aggregateData})
+
+	var sums struct {
+		Count int64
+		Value float64
+	}
+	err := db.Model(&Reading{}).Select(`sum("count") AS count, sum(value) AS value`).Scan(&sums).Error
+	if err != nil {
+		t.Fatalf("sum 失败: %v", err)
+	}
+	if sums.Count != 10 || sums.Value != 16 {
+		t.Errorf("sum 结果不正确: %+v", sums)
+	}
+
+	// UInt64 的结果可以扫描到 uint64，不会变成字符串
+	var bytes uint64
+	if err := db.Model(&Reading{}).Where("sensor = ?", "a").Select("sum(bytes)").Scan(&bytes).Error; err != nil {
+		t.Fatalf("sum(bytes) 失败: %v", err)
+	}
+	if bytes != 30 {
+		t.Errorf("sum(bytes) 结果不正确: %d", bytes)
+	}
+
+	// 超出 int64 范围的值不能扫描到 int64
+	var overflow int64
+	err = db.Model(&Reading{}).Where("sensor = ?", "b").Select("max(bytes)").Scan(&overflow).Error
+	if err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("期望溢出错误，得到 %d: %v", overflow, err)
+	}
+}
+
+func TestAggregateMinMaxTime(t *testing.T) {
+	_, db := openServer(t, serverOptions{Data: aggregateData})
+
+	var bounds struct {
+		First time.Time
+		Last  time.Time
+	}
+	err := db.Model(&Reading{}).Select("min(time) AS first, max(time) AS last").Scan(&bounds).Error
+	if err != nil {
+		t.Fatalf("min/max(time) 失败: %v", err)
+	}
+	if !bounds.First.Equal(time.Unix(1, 0)) || !bounds.Last.Equal(time.Unix(4, 0)) {
+		t.Errorf("时间范围不正确: %+v", bounds)
+	}
+
+	var last time.Time
+	if err := db.Model(&Reading{}).Where("sensor = ?", "a").Select("max(time)").Scan(&last).Error; err != nil {
+		t.Fatalf("max(time) 失败: %v", err)
+	}
+	if !last.Equal(time.Unix(2, 0)) {
+		t.Errorf("max(time) 结果不正确: %v", last)
+	}
+}
+
+func TestAggregatePercentile(t *testing.T) {
+	_, db := openServer(t, serverOptions{Data: aggregateData})
+
+	var results []struct {
+		Sensor string
+		P50    float64
+	}
+	err := db.Model(&Reading{}).Select("sensor, approx_percentile_cont(value, 0.5) AS p50").
+		Group("sensor").Order("sensor").Scan(&results).Error
+	if err != nil {
+		t.Fatalf("approx_percentile_cont 失败: %v", err)
+	}
+	if len(results) != 2 || results[0].P50 != 2 || results[1].P50 != 6 {
+		t.Errorf("approx_percentile_cont 结果不正确: %+v", results)
+	}
+}
+
+func TestAggregatePluck(t *testing.T) {
+	_, db := openServer(t, serverOptions{Data: aggregateData})
+
+	var counts []int64
+	if err := db.Model(&Reading{}).Order("time").Pluck("count", &counts).Error; err != nil {
+		t.Fatalf("Pluck int64 失败: %v", err)
+	}
+	if len(counts) != 4 || counts[0] != 1 || counts[3] != 4 {
+		t.Errorf("Pluck int64 结果不正确: %v", counts)
+	}
+
+	var bytes []uint64
+	if err := db.Model(&Reading{}).Where("bytes IS NOT NULL").Order("time").Pluck("bytes", &bytes).Error; err != nil {
+		t.Fatalf("Pluck uint64 失败: %v", err)
+	}
+	if len(bytes) != 3 || bytes[2] != 18446744073709551615 {
+		t.Errorf("Pluck uint64 结果不正确: %v", bytes)
+	}
+
+	var times []time.Time
+	if err := db.Model(&Reading{}).Where("sensor = ?", "b").Order("time").Pluck("time", &times).Error; err != nil {
+		t.Fatalf("Pluck time 失败: %v", err)
+	}
+	if len(times) != 2 || !times[0].Equal(time.Unix(3, 0)) {
+		t.Errorf("Pluck time 结果不正确: %v", times)
+	}
+
+	var rows []map[string]interface{}
+	if err := db.Model(&Reading{}).Select("bytes").Where("sensor = ?", "a").Order("time").Find(&rows).Error; err != nil {
+		t.Fatalf("查询 map 失败: %v", err)
+	}
+	if len(rows) != 2 || rows[0]["bytes"] != uint64(10) {
+		t.Errorf("map 中的值类型不正确: %#v", rows)
+	}
+}
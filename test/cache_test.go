@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	influxdb3gorm "github.com/xiabin827/influxdb3-gorm-driver"
+	"github.com/xiabin827/influxdb3-gorm-driver/dialector"
+)
+
+// cacheData 两个 sensor 各有多个数据点
+const cacheData = `readings,sensor=a value=1,count=1i,note="x" 1000000000
+readings,sensor=a value=2,count=2i,note="y" 2000000000
+readings,sensor=b value=3,count=3i,note="z" 1500000000
+`
+
+func TestLastCache(t *testing.T) {
+	srv, db := openServer(t, serverOptions{Data: cacheData})
+	m := db.Migrator().(dialector.Migrator)
+
+	// 键列默认取模型的 tag 字段，名称、数量和过期时间由服务端补齐
+	if err := m.CreateLastCache(&Reading{}, dialector.LastCacheOptions{}); err != nil {
+		t.Fatalf("创建 Last Value Cache 失败: %v", err)
+	}
+	err := m.CreateLastCache("readings", dialector.LastCacheOptions{
+		Name: "latest_value", KeyColumns: []string{"sensor"}, ValueColumns: []string{"value"}, Count: 2, TTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("创建 Last Value Cache 失败: %v", err)
+	}
+
+	caches, err := m.ListLastCaches(&Reading{})
+	if err != nil {
+		t.Fatalf("列出 Last Value Cache 失败: %v", err)
+	}
+	if len(caches) != 2 {
+		t.Fatalf("Last Value Cache 数量不正确: %+v", caches)
+	}
+	if c := caches[0]; c.Table != "readings" || c.Name != "readings_sensor_last_cache" ||
+		strings.Join(c.KeyColumns, ",") != "sensor" || c.ValueColumns != nil || c.Count != 1 || c.TTL != 4*time.Hour {
+		t.Errorf("默认的 Last Value Cache 不正确: %+v", c)
+	}
+	if c := caches[1]; c.Name != "latest_value" || strings.Join(c.ValueColumns, ",") != "value" || c.Count != 2 || c.TTL != time.Hour {
+		t.Errorf("Last Value Cache 不正确: %+v", c)
+	}
+
+	// 每个 sensor 的最新值
+	var latest []Reading
+	if err := db.Scopes(influxdb3gorm.LastValues("readings_sensor_last_cache")).Order("sensor").Find(&latest).Error; err != nil {
+		t.Fatalf("查询 Last Value Cache 失败: %v", err)
+	}
+	if len(latest) != 2 || latest[0].Value != 2 || latest[0].Note != "y" || latest[1].Value != 3 {
+		t.Errorf("Last Value Cache 查询结果不正确: %+v", latest)
+	}
+	queries := srv.Queries()
+	if last := queries[len(queries)-1].SQL; !strings.Contains(last, `FROM last_cache('readings', 'readings_sensor_last_cache') AS "readings"`) {
+		t.Errorf("查询没有使用 last_cache(): %s", last)
+	}
+
+	// 只缓存 value 列，每个 sensor 按时间从新到旧保留两行
+	var rows []map[string]interface{}
+	if err := db.Model(&Reading{}).Scopes(influxdb3gorm.LastValues("latest_value")).Find(&rows).Error; err != nil {
+		t.Fatalf("查询 Last Value Cache 失败: %v", err)
+	}
+	if len(rows) != 3 || len(rows[0]) != 3 || rows[0]["value"] != 2.0 || rows[1]["value"] != 1.0 || rows[2]["sensor"] != "b" {
+		t.Errorf("Last Value Cache 查询结果不正确: %v", rows)
+	}
+
+	if err := m.DropLastCache(&Reading{}, "latest_value"); err != nil {
+		t.Fatalf("删除 Last Value Cache 失败: %v", err)
+	}
+	if caches, err := m.ListLastCaches(&Reading{}); err != nil || len(caches) != 1 {
+		t.Errorf("删除后的 Last Value Cache 不正确: %+v, %v", caches, err)
+	}
+
+	// 服务端的错误原样返回
+	var serverErr *influxdb3.ServerError
+	if err := m.DropLastCache(&Reading{}, "latest_value"); !errors.As(err, &serverErr) || serverErr.StatusCode != 404 {
+		t.Errorf("删除不存在的缓存应返回 404，得到: %v", err)
+	}
+	if err := m.CreateLastCache(&Reading{}, dialector.LastCacheOptions{}); !errors.As(err, &serverErr) || serverErr.StatusCode != 409 {
+		t.Errorf("重复创建缓存应返回 409，得到: %v", err)
+	}
+	if err := m.CreateLastCache("missing", dialector.LastCacheOptions{}); !errors.As(err, &serverErr) || serverErr.StatusCode != 404 {
+		t.Errorf("表不存在时应返回 404，得到: %v", err)
+	}
+}
+
+func TestDistinctCache(t *testing.T) {
+	srv, db := openServer(t, serverOptions{Data: cacheData + `readings,sensor=c value=4 3000000000
+readings value=5 4000000000
+`})
+	m := db.Migrator().(dialector.Migrator)
+
+	// 没有缓存时回退为 SELECT DISTINCT
+	values, err := influxdb3gorm.DistinctTagValues(db, &Reading{}, "Sensor")
+	if err != nil {
+		t.Fatalf("查询不同取值失败: %v", err)
+	}
+	if strings.Join(values, ",") != "a,b,c" {
+		t.Errorf("不同取值不正确: %v", values)
+	}
+	queries := srv.Queries()
+	if n := len(queries); n < 2 || !strings.Contains(queries[n-2].SQL, "system.distinct_caches") ||
+		!strings.HasPrefix(queries[n-1].SQL, `SELECT DISTINCT "sensor" FROM "readings"`) {
+		t.Errorf("没有缓存时应查询系统表后回退为 SELECT DISTINCT: %+v", queries)
+	}
+
+	// 缓存列默认取模型的 tag 字段
+	if err := m.CreateDistinctCache(&Reading{}, dialector.DistinctCacheOptions{}); err != nil {
+		t.Fatalf("创建 Distinct Value Cache 失败: %v", err)
+	}
+	err = m.CreateDistinctCache("readings", dialector.DistinctCacheOptions{
+		Name: "sensor_notes", Columns: []string{"sensor", "note"}, MaxCardinality: 10, MaxAge: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("创建 Distinct Value Cache 失败: %v", err)
+	}
+
+	var caches []map[string]interface{}
+	if err := db.Raw(`SELECT name, array_to_string(column_names, ',') AS columns, max_cardinality, max_age_seconds
+		FROM system.distinct_caches WHERE "table" = 'readings' ORDER BY name`).Scan(&caches).Error; err != nil {
+		t.Fatalf("查询 system.distinct_caches 失败: %v", err)
+	}
+	if len(caches) != 2 || caches[0]["name"] != "readings_sensor_distinct_cache" || caches[0]["columns"] != "sensor" ||
+		caches[0]["max_cardinality"] != uint64(100000) || caches[0]["max_age_seconds"] != uint64(86400) ||
+		caches[1]["columns"] != "sensor,note" || caches[1]["max_cardinality"] != uint64(10) || caches[1]["max_age_seconds"] != uint64(3600) {
+		t.Errorf("system.distinct_caches 不正确: %v", caches)
+	}
+
+	// 存在包含该列的缓存时通过 distinct_cache() 读取
+	values, err = influxdb3gorm.DistinctTagValues(db, &Reading{}, "sensor")
+	if err != nil {
+		t.Fatalf("查询不同取值失败: %v", err)
+	}
+	if strings.Join(values, ",") != "a,b,c" {
+		t.Errorf("不同取值不正确: %v", values)
+	}
+	queries = srv.Queries()
+	if last := queries[len(queries)-1].SQL; !strings.Contains(last, `FROM distinct_cache('readings', 'readings_sensor_distinct_cache') AS "readings"`) {
+		t.Errorf("查询没有使用 distinct_cache(): %s", last)
+	}
+
+	// 多列缓存返回各列取值的组合，第一列为 NULL 的数据点不在缓存中
+	var rows []map[string]interface{}
+	if err := db.Model(&Reading{}).Scopes(influxdb3gorm.DistinctValues("sensor_notes")).Find(&rows).Error; err != nil {
+		t.Fatalf("查询 Distinct Value Cache 失败: %v", err)
+	}
+	if len(rows) != 4 || rows[0]["note"] != "x" || rows[1]["note"] != "y" || rows[2]["sensor"] != "b" ||
+		rows[3]["sensor"] != "c" || rows[3]["note"] != nil {
+		t.Errorf("Distinct Value Cache 查询结果不正确: %v", rows)
+	}
+
+	if err := m.DropDistinctCache(&Reading{}, "sensor_notes"); err != nil {
+		t.Fatalf("删除 Distinct Value Cache 失败: %v", err)
+	}
+	var serverErr *influxdb3.ServerError
+	if err := m.DropDistinctCache(&Reading{}, "sensor_notes"); !errors.As(err, &serverErr) || serverErr.StatusCode != 404 {
+		t.Errorf("删除不存在的缓存应返回 404，得到: %v", err)
+	}
+	if err := m.CreateDistinctCache(&Reading{}, dialector.DistinctCacheOptions{}); !errors.As(err, &serverErr) || serverErr.StatusCode != 409 {
+		t.Errorf("重复创建缓存应返回 409，得到: %v", err)
+	}
+	if err := m.CreateDistinctCache("readings", dialector.DistinctCacheOptions{Columns: []string{"value"}}); !errors.As(err, &serverErr) || serverErr.StatusCode != 400 {
+		t.Errorf("缓存非字符串列应返回 400，得到: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	influxdb3gorm "github.com/xiabin827/influxdb3-gorm-driver"
+	"github.com/xiabin827/influxdb3-gorm-driver/dialector"
+	"gorm.io/gorm"
+)
+
+// Mark 以 influx:time 标记时间戳字段的模型
+type Mark struct {
+	Host  string    `gorm:"column:host;type:tag"`
+	Value float64   `gorm:"column:value"`
+	At    time.Time `gorm:"column:at;influx:time"`
+}
+
+func (Mark) TableName() string { return "marks" }
+
+// deleteRequest 删除接口收到的请求
+type deleteRequest struct {
+	Path string
+	Body map[string]string
+}
+
+// openDeleteAPI 打开只提供 HTTP 接口的连接，status 为删除接口返回的状态码
+func openDeleteAPI(t *testing.T, status int) (*gorm.DB, *[]deleteRequest) {
+	t.Helper()
+	var requests []deleteRequest
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := deleteRequest{Path: r.URL.Path + "?" + r.URL.RawQuery}
+		if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+			if err := json.Unmarshal(data, &req.Body); err != nil {
+				t.Errorf("请求体不是 JSON: %s", data)
+			}
+		}
+		requests = append(requests, req)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(api.Close)
+
+	db, err := gorm.Open(influxdb3gorm.New(dialector.Config{
+		Host:     api.URL,
+		Database: "test",
+		Conn:     &dialector.InfluxDBConnPool{},
+	}), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	return db, &requests
+}
+
+func TestDeletePredicate(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		delete func(tx *gorm.DB) error
+		want   map[string]string
+	}{
+		{"time 列", func(tx *gorm.DB) error {
+			return tx.Where("time >= ? AND time < ?", start, end).Where("sensor = ?", `a"b`).Delete(&Reading{}).Error
+		}, map[string]string{
+			"start":     "2024-01-01T00:00:00Z",
+			"stop":      "2024-01-01T00:59:59.999999999Z",
+			"predicate": `_measurement="readings" AND sensor="a\"b"`,
+		}},
+		{"influx:time 字段", func(tx *gorm.DB) error {
+			return tx.Where("at > ?", start).Where("host = ?", "h1").Delete(&Mark{}).Error
+		}, map[string]string{
+			"start":     "2024-01-01T00:00:00.000000001Z",
+			"stop":      "2262-04-11T23:47:16.854775806Z",
+			"predicate": `_measurement="marks" AND host="h1"`,
+		}},
+		{"结构体条件", func(tx *gorm.DB) error {
+			return tx.Where(&Mark{Host: "h2", At: end}).Delete(&Mark{}).Error
+		}, map[string]string{
+			"start":     "2024-01-01T01:00:00Z",
+			"stop":      "2024-01-01T01:00:00Z",
+			"predicate": `_measurement="marks" AND host="h2"`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, requests := openDeleteAPI(t, http.StatusNoContent)
+			if err := tt.delete(db); err != nil {
+				t.Fatalf("删除失败: %v", err)
+			}
+			if len(*requests) != 1 || (*requests)[0].Path != "/api/v2/delete?bucket=test" {
+				t.Fatalf("删除请求不正确: %+v", *requests)
+			}
+			got := (*requests)[0].Body
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("%s 不正确: %q，期望 %q", key, got[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestDeletePredicateError(t *testing.T) {
+	db, requests := openDeleteAPI(t, http.StatusNoContent)
+	for name, tx := range map[string]*gorm.DB{
+		"field 列": db.Where("value = ?", 1.5),
+		"tag 范围":  db.Where("host > ?", "a"),
+		"OR 条件":   db.Where("host = ?", "a").Or("host = ?", "b"),
+		"时间戳不是时间": db.Where("at < ?", 100),
+		"复杂 SQL":  db.Where("host IN ?", []string{"a"}),
+	} {
+		var predErr *dialector.DeletePredicateError
+		if err := tx.Delete(&Mark{}).Error; !errors.As(err, &predErr) {
+			t.Errorf("%s: 期望 DeletePredicateError，得到: %v", name, err)
+		}
+	}
+	if len(*requests) != 0 {
+		t.Errorf("不支持的条件不应发送请求: %+v", *requests)
+	}
+
+	// 服务端不支持按条件删除时保留原始错误，删除接口的 404 不会被转换为表不存在
+	db, _ = openDeleteAPI(t, http.StatusNotFound)
+	err := db.Where("host = ?", "a").Delete(&Mark{}).Error
+	var predErr *dialector.DeletePredicateError
+	if !errors.As(err, &predErr) || predErr.Err == nil || !strings.Contains(err.Error(), "只能删除整个表") {
+		t.Errorf("期望服务端不支持的 DeletePredicateError，得到: %v", err)
+	}
+	if errors.Is(err, dialector.ErrTableNotFound) {
+		t.Errorf("删除接口不支持按条件删除时不应报告表不存在: %v", err)
+	}
+}
+
+func TestDeleteDryRun(t *testing.T) {
+	db, requests := openDeleteAPI(t, http.StatusNoContent)
+	dryRun := db.Session(&gorm.Session{DryRun: true, AllowGlobalUpdate: true})
+	if err := dryRun.Delete(&Mark{}).Error; err != nil {
+		t.Fatalf("DryRun 删除整个表失败: %v", err)
+	}
+	if err := dryRun.Where("host = ?", "a").Delete(&Mark{}).Error; err != nil {
+		t.Fatalf("DryRun 按条件删除失败: %v", err)
+	}
+	if len(*requests) != 0 {
+		t.Errorf("DryRun 不应发送删除请求: %+v", *requests)
+	}
+
+	// 不符合要求的条件在 DryRun 时同样返回错误
+	var predErr *dialector.DeletePredicateError
+	if err := dryRun.Where("value = ?", 1).Delete(&Mark{}).Error; !errors.As(err, &predErr) {
+		t.Errorf("期望 DeletePredicateError，得到: %v", err)
+	}
+}
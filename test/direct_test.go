@@ -6,13 +6,21 @@ import (
 	"testing"
 
 	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"github.com/xiabin827/influxdb3-gorm-driver/influxdb3test"
 )
 
+// userActionsData 测试用的用户行为数据
+const userActionsData = `user_actions,region=beijing,sex=male action_type=1i,app_id_str="app1",brand="apple",custom_event="login",duration=30i,user_id="u1" 1700000000000000000
+user_actions,region=shanghai,sex=female action_type=2i,app_id_str="app1",brand="huawei",custom_event="pay",duration=120i,user_id="u2" 1700000001000000000
+user_actions,region=beijing,sex=female action_type=1i,app_id_str="app2",brand="xiaomi",custom_event="login",duration=45i,user_id="u3" 1700000002000000000
+`
+
 func TestDirectClientQuery(t *testing.T) {
+	srv, _ := openServer(t, serverOptions{Data: userActionsData, Server: influxdb3test.Options{Database: "user_actions"}})
 	client, err := influxdb3.New(influxdb3.ClientConfig{
-		Host:     "http://localhost:8181",
-		Token:    "apiv3_sdgB9mevgBIKCSyDliGrASsMAEUVyoKjCUdlXoHn43tMkX3ATa0A1WwDetGng2EdaGX8HaSB1rAoVgmkVzQ9PA",
-		Database: "user_actions",
+		Host:     srv.URL,
+		Token:    srv.Token,
+		Database: srv.Database,
 	})
 	if err != nil {
 		t.Fatalf("无法创建客户端: %v", err)
@@ -54,4 +62,16 @@ func TestDirectClientQuery(t *testing.T) {
 	}
 
 	fmt.Printf("共查询到 %d 条记录\n", count)
+	if count != 3 {
+		t.Fatalf("期望查询到 3 条记录，实际 %d 条", count)
+	}
+	if len(columns) != 9 {
+		t.Errorf("期望 9 列，实际 %d 列: %v", len(columns), columns)
+	}
+
+	// 打开 GORM 连接时的 SELECT VERSION() 之后只有这一次查询
+	queries := srv.Queries()
+	if len(queries) != 2 || queries[1].SQL != query {
+		t.Errorf("服务端收到的查询不正确: %+v", queries)
+	}
 }
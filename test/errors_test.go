@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	influxdb3gorm "github.com/xiabin827/influxdb3-gorm-driver"
+	"github.com/xiabin827/influxdb3-gorm-driver/dialector"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+func TestTranslateError(t *testing.T) {
+	d := influxdb3gorm.New(dialector.Config{}).(*dialector.Dialector)
+	serverErr := func(code int, message string) error {
+		return &influxdb3.ServerError{StatusCode: code, Message: message}
+	}
+
+	for _, tt := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"HTTP 401", serverErr(http.StatusUnauthorized, "unauthorized"), dialector.ErrUnauthorized},
+		{"HTTP 403", serverErr(http.StatusForbidden, "forbidden"), dialector.ErrUnauthorized},
+		{"HTTP 404 表", serverErr(http.StatusNotFound, "table not found: readings"), dialector.ErrTableNotFound},
+		{"HTTP 404 数据库", serverErr(http.StatusNotFound, "database not found: test"), dialector.ErrDatabaseNotFound},
+		{"HTTP 400", serverErr(http.StatusBadRequest, "bad request"), dialector.ErrInvalidQuery},
+		{"gRPC Unauthenticated", status.Error(codes.Unauthenticated, "Unauthenticated"), dialector.ErrUnauthorized},
+		{"gRPC PermissionDenied", status.Error(codes.PermissionDenied, "denied"), dialector.ErrUnauthorized},
+		{"gRPC NotFound", status.Error(codes.NotFound, "database not found: test"), dialector.ErrDatabaseNotFound},
+		{"gRPC 规划时找不到表", status.Error(codes.InvalidArgument, "Error during planning: table 'public.iox.nope' not found"), dialector.ErrTableNotFound},
+		{"gRPC InvalidArgument", status.Error(codes.InvalidArgument, "Schema error: No field named nope"), dialector.ErrInvalidQuery},
+	} {
+		got := d.Translate(tt.err)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: 期望 %v，得到: %v", tt.name, tt.want, got)
+		}
+		// 原始错误仍然可以取出
+		if !errors.Is(got, tt.err) {
+			t.Errorf("%s: 转换后丢失了原始错误: %v", tt.name, got)
+		}
+	}
+
+	// 无法对应的错误和 GORM 的错误保持不变
+	for _, err := range []error{
+		serverErr(http.StatusInternalServerError, "internal"),
+		status.Error(codes.Unavailable, "unavailable"),
+		gorm.ErrRecordNotFound,
+		nil,
+	} {
+		if got := d.Translate(err); got != err {
+			t.Errorf("错误不应被转换: %v -> %v", err, got)
+		}
+	}
+
+	// 已经转换过的错误不会重复包装
+	translated := d.Translate(serverErr(http.StatusBadRequest, "bad request"))
+	if got := d.Translate(translated); got != translated {
+		t.Errorf("重复转换: %v", got)
+	}
+}
+
+func TestServerErrorTaxonomy(t *testing.T) {
+	srv, db := openServer(t, serverOptions{Data: cacheData})
+
+	var count int64
+	for name, tt := range map[string]struct {
+		err  error
+		want error
+	}{
+		"Find 表不存在":  {db.Table("missing").Find(&[]map[string]interface{}{}).Error, dialector.ErrTableNotFound},
+		"Count 表不存在": {db.Table("missing").Count(&count).Error, dialector.ErrTableNotFound},
+		"Scan 无效的查询": {db.Raw("SELECT missing FROM readings").Scan(&[]map[string]interface{}{}).Error, dialector.ErrInvalidQuery},
+		"INSERT":     {db.Exec("INSERT INTO readings (value) VALUES (1)").Error, dialector.ErrUnsupportedStatement},
+		"UPDATE":     {db.Exec("UPDATE readings SET value = 1").Error, dialector.ErrUnsupportedStatement},
+		"记录不存在":      {db.Where("sensor = ?", "missing").First(&Reading{}).Error, gorm.ErrRecordNotFound},
+	} {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: 期望 %v，得到: %v", name, tt.want, tt.err)
+		}
+	}
+
+	// 原始的 gRPC 状态码仍然可以取出
+	err := db.Raw("SELECT missing FROM readings").Scan(&[]map[string]interface{}{}).Error
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("转换后应保留 gRPC 状态码，得到: %v", err)
+	}
+
+	// 写入接口的认证失败
+	config := srv.Config()
+	unauthorized, err := gorm.Open(influxdb3gorm.New(dialector.Config{
+		Host:     config.Host,
+		Token:    "wrong",
+		Database: config.Database,
+		Conn:     &dialector.InfluxDBConnPool{},
+	}), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	err = unauthorized.Create(&Reading{Sensor: "a", Value: 1}).Error
+	var serverErr *influxdb3.ServerError
+	if !errors.Is(err, dialector.ErrUnauthorized) || !errors.As(err, &serverErr) || serverErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("期望 ErrUnauthorized，得到: %v", err)
+	}
+
+	// 未开启 TranslateError 时返回原始错误
+	plain, err := gorm.Open(influxdb3gorm.New(config), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	err = plain.Table("missing").Find(&[]map[string]interface{}{}).Error
+	if err == nil || errors.Is(err, dialector.ErrTableNotFound) || status.Code(err) != codes.InvalidArgument {
+		t.Errorf("未开启 TranslateError 时不应转换错误: %v", err)
+	}
+}
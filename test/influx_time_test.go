@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Stamp 以 influx:time 标记时间戳字段，同时带有 time 列字段的模型
+type Stamp struct {
+	Host  string    `gorm:"column:host;type:tag"`
+	Value float64   `gorm:"column:value"`
+	Time  time.Time `gorm:"column:time"`
+	At    time.Time `gorm:"column:at;influx:time;autoCreateTime"`
+}
+
+func (Stamp) TableName() string { return "stamps" }
+
+func TestInfluxTimeWrite(t *testing.T) {
+	srv, db := openServer(t)
+	before := time.Now()
+	stamps := []Stamp{
+		{Host: "a", Value: 1, Time: time.Unix(9, 0), At: time.Unix(1, 0)},
+		{Host: "b", Value: 2},
+	}
+	if err := db.Create(&stamps).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	// Time 字段既不作为时间戳也不作为 field 写入
+	lines := srv.Lines("stamps")
+	if len(lines) != 2 || lines[0] != "stamps,host=a value=1 1000000000" {
+		t.Fatalf("写入的行协议不正确: %q", lines)
+	}
+	if !strings.HasPrefix(lines[1], "stamps,host=b value=2 ") || stamps[1].At.Before(before) {
+		t.Errorf("autoCreateTime 没有设置时间戳: %q, %v", lines[1], stamps[1].At)
+	}
+
+	var found Stamp
+	if err := db.Where("host = ?", "a").First(&found).Error; err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if !found.At.Equal(time.Unix(1, 0)) || found.Value != 1 {
+		t.Errorf("查询结果不正确: %+v", found)
+	}
+
+	// 更新按 influx:time 字段定位数据点
+	if err := db.Model(&found).Update("value", 3).Error; err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+	if lines := srv.Lines("stamps"); len(lines) != 2 || lines[0] != "stamps,host=a value=3 1000000000" {
+		t.Errorf("更新后的行协议不正确: %q", lines)
+	}
+}
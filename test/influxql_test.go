@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"github.com/xiabin827/influxdb3-gorm-driver/dialector"
+	"gorm.io/gorm"
+)
+
+func TestSessionQueryType(t *testing.T) {
+	srv, db := openServer(t, serverOptions{Data: cacheData})
+	influxQL := func() *gorm.DB {
+		return db.Set(dialector.QueryTypeKey, "influxql").Session(&gorm.Session{})
+	}
+
+	// 测试服务端不执行 InfluxQL，但会记录收到的查询语言
+	var count int64
+	for _, tt := range []struct {
+		name string
+		run  func() error
+		want string
+	}{
+		{"Raw", func() error {
+			return influxQL().Raw("SHOW MEASUREMENTS").Scan(&[]map[string]interface{}{}).Error
+		}, "SHOW MEASUREMENTS"},
+		{"Find", func() error {
+			return influxQL().Where("sensor = ?", "a").Find(&[]Reading{}).Error
+		}, `SELECT * FROM "readings" WHERE sensor = 'a'`},
+		{"Count", func() error {
+			return influxQL().Model(&Reading{}).Count(&count).Error
+		}, `SELECT count(*) FROM "readings"`},
+		{"Exec", func() error {
+			return influxQL().Exec("SHOW DATABASES").Error
+		}, "SHOW DATABASES"},
+	} {
+		sent := len(srv.Queries())
+		if err := tt.run(); err == nil {
+			t.Errorf("%s: 测试服务端应拒绝 InfluxQL 查询", tt.name)
+		}
+		queries := srv.Queries()
+		if len(queries) != sent+1 || queries[sent].QueryType != "influxql" || queries[sent].SQL != tt.want {
+			t.Errorf("%s: 查询语言或语句没有传递到服务端: %+v", tt.name, queries[sent:])
+		}
+	}
+
+	// 会话设置不影响其他查询
+	if err := db.Find(&[]Reading{}).Error; err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	queries := srv.Queries()
+	if last := queries[len(queries)-1]; last.QueryType != "sql" {
+		t.Errorf("未设置查询语言的会话应使用 SQL: %+v", last)
+	}
+
+	if err := db.Set(dialector.QueryTypeKey, "flux").Find(&[]Reading{}).Error; !errors.Is(err, gorm.ErrInvalidValue) {
+		t.Errorf("不支持的查询语言应返回 ErrInvalidValue，得到: %v", err)
+	}
+}
+
+func TestConfigQueryType(t *testing.T) {
+	srv, db := openServer(t, serverOptions{
+		Data:   cacheData,
+		Config: func(c *dialector.Config) { c.QueryType = influxdb3.InfluxQL },
+	})
+
+	sent := len(srv.Queries())
+	if err := db.Raw("SHOW MEASUREMENTS").Scan(&[]map[string]interface{}{}).Error; err == nil {
+		t.Error("测试服务端应拒绝 InfluxQL 查询")
+	}
+
+	// 会话设置优先于配置
+	var sensors []string
+	if err := db.Set(dialector.QueryTypeKey, "sql").Model(&Reading{}).Distinct("sensor").Order("sensor").Pluck("sensor", &sensors).Error; err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(sensors) != 2 {
+		t.Errorf("查询结果不正确: %v", sensors)
+	}
+
+	queries := srv.Queries()[sent:]
+	if len(queries) != 2 || queries[0].QueryType != "influxql" || queries[1].QueryType != "sql" {
+		t.Errorf("查询语言不正确: %+v", queries)
+	}
+}
+
+func TestInfluxQLQueryText(t *testing.T) {
+	srv, db := openServer(t, serverOptions{Data: cacheData})
+	influxQL := func() *gorm.DB {
+		return db.Set(dialector.QueryTypeKey, "influxql").Session(&gorm.Session{})
+	}
+
+	for _, tt := range []struct {
+		name  string
+		query func(tx *gorm.DB) *gorm.DB
+		want  string
+	}{
+		{"Where", func(tx *gorm.DB) *gorm.DB { return tx.Where("sensor = ?", "a").Where("value > ?", 1) },
+			`SELECT * FROM "readings" WHERE sensor = 'a' AND value > 1`},
+		{"IN", func(tx *gorm.DB) *gorm.DB { return tx.Where("sensor IN ?", []string{"a", "b"}) },
+			`SELECT * FROM "readings" WHERE ("sensor" = 'a' OR "sensor" = 'b')`},
+		{"NOT IN", func(tx *gorm.DB) *gorm.DB { return tx.Where(`"sensor" NOT IN ?`, []string{"a", "b"}) },
+			`SELECT * FROM "readings" WHERE "sensor" <> 'a' AND "sensor" <> 'b'`},
+		{"map IN", func(tx *gorm.DB) *gorm.DB { return tx.Where(map[string]interface{}{"sensor": []string{"a", "b"}}) },
+			`SELECT * FROM "readings" WHERE ("sensor" = 'a' OR "sensor" = 'b')`},
+		{"Order 和 Limit", func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("sensor IN (?)", []string{"a"}).Order("time DESC").Limit(2)
+		},
+			`SELECT * FROM "readings" WHERE "sensor" = 'a' ORDER BY time DESC LIMIT 2`},
+		// InfluxQL 的字符串使用反斜杠转义，参数不能提前结束字符串
+		{"转义", func(tx *gorm.DB) *gorm.DB { return tx.Where("sensor = ?", `\' OR sensor =~ /.*/ --`) },
+			`SELECT * FROM "readings" WHERE sensor = '\\\' OR sensor =~ /.*/ --'`},
+	} {
+		sent := len(srv.Queries())
+		tt.query(influxQL()).Find(&[]Reading{})
+		queries := srv.Queries()
+		if len(queries) != sent+1 || queries[sent].SQL != tt.want {
+			t.Errorf("%s: 发送的 InfluxQL 不正确\n得到: %+v\n期望: %s", tt.name, queries[sent:], tt.want)
+		}
+	}
+
+	// 无法展开的 IN 条件不会发送到服务端
+	sent := len(srv.Queries())
+	err := influxQL().Where("sensor IN ('a', 'b')").Find(&[]Reading{}).Error
+	if !errors.Is(err, dialector.ErrUnsupportedStatement) {
+		t.Errorf("期望 ErrUnsupportedStatement，得到: %v", err)
+	}
+	if queries := srv.Queries(); len(queries) != sent {
+		t.Errorf("不支持的 IN 条件不应发送查询: %+v", queries[sent:])
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/InfluxCommunity/influxdb3-go/v2/influxdb3"
+	"github.com/influxdata/line-protocol/v2/lineprotocol"
+	influxdb3gorm "github.com/xiabin827/influxdb3-gorm-driver"
+	"github.com/xiabin827/influxdb3-gorm-driver/dialector"
+	"gorm.io/gorm"
+)
+
+func TestEncodePoints(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 123, time.UTC)
+
+	tests := []struct {
+		name  string
+		point *influxdb3.Point
+		want  string
+	}{
+		{
+			name: "基本类型",
+			point: influxdb3.NewPointWithMeasurement("cpu").
+				SetTag("host", "a").
+				SetField("f", 1.5).
+				SetField("i", -3).
+				SetField("u", uint64(math.MaxUint64)).
+				SetField("b", true).
+				SetField("s", "ok").
+				SetTimestamp(ts),
+			want: `cpu,host=a b=true,f=1.5,i=-3i,s="ok",u=18446744073709551615u 1704067200000000123` + "\n",
+		},
+		{
+			name: "tag 和 field 按键排序",
+			point: influxdb3.NewPointWithMeasurement("m").
+				SetTag("z", "1").
+				SetTag("a", "2").
+				SetField("y", 1.0).
+				SetField("b", 2.0),
+			want: "m,a=2,z=1 b=2,y=1\n",
+		},
+		{
+			name: "measurement 转义逗号和空格",
+			point: influxdb3.NewPointWithMeasurement("my measurement,x=1").
+				SetField("v", 1.0),
+			want: `my\ measurement\,x=1 v=1` + "\n",
+		},
+		{
+			name: "tag 和 field 键转义逗号、等号和空格",
+			point: influxdb3.NewPointWithMeasurement("m").
+				SetTag("tag key,=", "tag value,=").
+				SetField("field key,=", 1.0),
+			want: `m,tag\ key\,\==tag\ value\,\= field\ key\,\==1` + "\n",
+		},
+		{
+			name: "字符串转义双引号和反斜杠",
+			point: influxdb3.NewPointWithMeasurement("m").
+				SetField("s", `say "hi" \ bye`),
+			want: `m s="say \"hi\" \\ bye"` + "\n",
+		},
+		{
+			name: "各种宽度的整数",
+			point: influxdb3.NewPointWithMeasurement("m").
+				SetField("i8", int8(-8)).
+				SetField("i32", int32(32)).
+				SetField("u8", uint8(8)).
+				SetField("u", uint(7)),
+			want: "m i32=32i,i8=-8i,u=7u,u8=8u\n",
+		},
+		{
+			name: "1970 年之前的时间戳",
+			point: influxdb3.NewPointWithMeasurement("m").
+				SetField("v", 1.0).
+				SetTimestamp(time.Unix(0, -1500)),
+			want: "m v=1 -1500\n",
+		},
+		{
+			name: "float32 使用最短表示",
+			point: influxdb3.NewPointWithMeasurement("m").
+				SetField("f", float32(0.1)),
+			want: "m f=0.1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dialector.EncodePoints([]*influxdb3.Point{tt.point})
+			if err != nil {
+				t.Fatalf("编码失败: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("编码结果不正确\n得到: %q\n期望: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodePointsMultipleLines(t *testing.T) {
+	points := []*influxdb3.Point{
+		influxdb3.NewPointWithMeasurement("m").SetField("v", 1.0).SetTimestamp(time.Unix(1, 0)),
+		influxdb3.NewPointWithMeasurement("m").SetField("v", 2.0).SetTimestamp(time.Unix(2, 0)),
+	}
+	got, err := dialector.EncodePoints(points)
+	if err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	want := "m v=1 1000000000\nm v=2 2000000000\n"
+	if string(got) != want {
+		t.Errorf("编码结果不正确\n得到: %q\n期望: %q", got, want)
+	}
+}
+
+func TestEncodeNegativeTimestampPrecision(t *testing.T) {
+	// 低精度写入时向下取整，-1500ms 按秒为 -2 而不是 -1
+	ts := time.UnixMilli(-1500)
+	for _, tt := range []struct {
+		precision lineprotocol.Precision
+		want      string
+	}{
+		{lineprotocol.Second, " -2"},
+		{lineprotocol.Millisecond, " -1500"},
+		{lineprotocol.Microsecond, " -1500000"},
+	} {
+		db, err := gorm.Open(influxdb3gorm.New(dialector.Config{
+			Database:       "test",
+			Conn:           &dialector.InfluxDBConnPool{},
+			WritePrecision: tt.precision,
+		}), &gorm.Config{})
+		if err != nil {
+			t.Fatalf("打开数据库失败: %v", err)
+		}
+		tx := db.Session(&gorm.Session{DryRun: true}).Create(&Reading{Sensor: "a", Value: 1, Time: ts})
+		preview, ok := dialector.Preview(tx)
+		if tx.Error != nil || !ok {
+			t.Fatalf("DryRun 写入失败: %v", tx.Error)
+		}
+		if !strings.HasSuffix(preview.LineProtocol, tt.want+"\n") {
+			t.Errorf("精度 %s 的时间戳不正确: %q，期望以 %q 结尾", tt.precision, preview.LineProtocol, tt.want)
+		}
+	}
+}
+
+func TestEncodePointsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		point *influxdb3.Point
+		want  string
+	}{
+		{"NaN", influxdb3.NewPointWithMeasurement("m").SetField("v", math.NaN()), "不支持的浮点数"},
+		{"正无穷", influxdb3.NewPointWithMeasurement("m").SetField("v", math.Inf(1)), "不支持的浮点数"},
+		{"负无穷", influxdb3.NewPointWithMeasurement("m").SetField("v", math.Inf(-1)), "不支持的浮点数"},
+		{"没有 field", influxdb3.NewPointWithMeasurement("m").SetTag("t", "v"), "至少需要一个 field"},
+		{"空的 measurement", influxdb3.NewPointWithMeasurement("").SetField("v", 1.0), "measurement 不能为空"},
+		{"空的 tag 键", influxdb3.NewPointWithMeasurement("m").SetTag("", "v").SetField("v", 1.0), "tag 键 不能为空"},
+		{"换行符", influxdb3.NewPointWithMeasurement("m").SetTag("t", "a\nb").SetField("v", 1.0), "包含控制字符"},
+		{"反斜杠结尾", influxdb3.NewPointWithMeasurement("m").SetField(`k\`, 1.0), "不能以反斜杠结尾"},
+		{"不支持的类型", influxdb3.NewPointWithMeasurement("m").SetField("v", []int{1}), "不支持的 field 类型"},
+		{"时间戳超出范围", influxdb3.NewPointWithMeasurement("m").SetField("v", 1.0).SetTimestamp(time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC)), "超出范围"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := dialector.EncodePoints([]*influxdb3.Point{tt.point})
+			if err == nil {
+				t.Fatalf("期望返回错误")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("错误信息不正确: %v，期望包含 %q", err, tt.want)
+			}
+		})
+	}
+}
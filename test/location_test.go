@@ -0,0 +1,133 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	influxdb3gorm "github.com/xiabin827/influxdb3-gorm-driver"
+	"github.com/xiabin827/influxdb3-gorm-driver/dialector"
+	"github.com/xiabin827/influxdb3-gorm-driver/influxdb3test"
+)
+
+// Sample 以多种类型接收时间戳的模型
+type Sample struct {
+	Sensor string       `gorm:"column:sensor;type:tag"`
+	Value  float64      `gorm:"column:value"`
+	Time   time.Time    `gorm:"column:time"`
+	Ptr    *time.Time   `gorm:"-:migration;->;column:ptr"`
+	Null   sql.NullTime `gorm:"-:migration;->;column:null"`
+	Epoch  int64        `gorm:"-:migration;->;column:epoch;autoCreateTime:milli"`
+}
+
+func (Sample) TableName() string { return "readings" }
+
+// timestampData 带有纳秒时间戳的数据点
+const timestampData = "readings,sensor=a value=1 1700000000123456789\n"
+
+// timestampOptions 以 unit 为时间戳单位的服务端，loc 为查询结果的时区
+func timestampOptions(unit time.Duration, loc *time.Location) serverOptions {
+	return serverOptions{
+		Data:   timestampData,
+		Server: influxdb3test.Options{TimestampUnit: unit},
+		Config: func(c *dialector.Config) { c.Location = loc },
+	}
+}
+
+func TestTimestampUnits(t *testing.T) {
+	ts := time.Unix(0, 1700000000123456789)
+	for _, tt := range []struct {
+		unit time.Duration
+		want time.Time
+	}{
+		{time.Nanosecond, ts},
+		{time.Microsecond, ts.Truncate(time.Microsecond)},
+		{time.Millisecond, ts.Truncate(time.Millisecond)},
+	} {
+		t.Run(tt.unit.String(), func(t *testing.T) {
+			_, db := openServer(t, timestampOptions(tt.unit, nil))
+			query := db.Model(&Sample{}).Select("sensor, value, time, time AS ptr, time AS null, time AS epoch")
+
+			check := func(name string, s Sample) {
+				t.Helper()
+				if !s.Time.Equal(tt.want) || s.Ptr == nil || !s.Ptr.Equal(tt.want) ||
+					!s.Null.Valid || !s.Null.Time.Equal(tt.want) || s.Epoch != tt.want.UnixMilli() {
+					t.Errorf("%s 结果不正确: %+v", name, s)
+				}
+			}
+
+			var samples []Sample
+			if err := query.Find(&samples).Error; err != nil {
+				t.Fatalf("查询失败: %v", err)
+			}
+			if len(samples) != 1 {
+				t.Fatalf("查询结果数量不正确: %d", len(samples))
+			}
+			check("查询", samples[0])
+
+			for s, err := range influxdb3gorm.Stream[Sample](query) {
+				if err != nil {
+					t.Fatalf("流式读取失败: %v", err)
+				}
+				check("流式读取", s)
+			}
+
+			var times []time.Time
+			if err := db.Model(&Sample{}).Pluck("time", &times).Error; err != nil {
+				t.Fatalf("Pluck 失败: %v", err)
+			}
+			if len(times) != 1 || !times[0].Equal(tt.want) {
+				t.Errorf("Pluck 结果不正确: %v", times)
+			}
+		})
+	}
+}
+
+func TestTimestampLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	_, db := openServer(t, timestampOptions(time.Millisecond, loc))
+
+	var samples []Sample
+	err := db.Model(&Sample{}).Select("sensor, value, time, time AS ptr, time AS null").Find(&samples).Error
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(samples) != 1 {
+		t.Fatalf("查询结果数量不正确: %d", len(samples))
+	}
+	s := samples[0]
+	if s.Time.Location() != loc || s.Ptr.Location() != loc || s.Null.Time.Location() != loc {
+		t.Errorf("时间戳没有转换到指定时区: %v %v %v", s.Time, s.Ptr, s.Null.Time)
+	}
+	if s.Time.Hour() != 6 || !s.Time.Equal(time.UnixMilli(1700000000123)) {
+		t.Errorf("时间戳不正确: %v", s.Time)
+	}
+
+	var rows []map[string]interface{}
+	if err := db.Model(&Sample{}).Select("time").Find(&rows).Error; err != nil {
+		t.Fatalf("查询 map 失败: %v", err)
+	}
+	if v, ok := rows[0]["time"].(time.Time); !ok || v.Location() != loc {
+		t.Errorf("map 中的时间戳时区不正确: %#v", rows[0]["time"])
+	}
+
+	for s, err := range influxdb3gorm.Stream[Sample](db.Model(&Sample{})) {
+		if err != nil {
+			t.Fatalf("流式读取失败: %v", err)
+		}
+		if s.Time.Location() != loc {
+			t.Errorf("流式读取的时间戳时区不正确: %v", s.Time)
+		}
+	}
+}
+
+func TestTimestampLocationDSN(t *testing.T) {
+	d := dialector.Open("host=http://localhost:8181 token=t database=db loc=Asia/Shanghai").(*dialector.Dialector)
+	if d.Location == nil || d.Location.String() != "Asia/Shanghai" {
+		t.Errorf("loc 解析不正确: %v", d.Location)
+	}
+	d = dialector.Open("host=http://localhost:8181 token=t database=db loc=Nowhere/City").(*dialector.Dialector)
+	if d.Location != nil {
+		t.Errorf("无效的 loc 应被忽略: %v", d.Location)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	influxdb3gorm "github.com/xiabin827/influxdb3-gorm-driver"
+	"github.com/xiabin827/influxdb3-gorm-driver/influxdb3test"
+)
+
+// nestedData 测试字典编码和嵌套类型用的数据
+const nestedData = `readings,sensor=a value=1.5,note="x" 1000000000
+readings,sensor=a value=2.5,note="y" 2000000000
+readings,sensor=b value=4,note="z" 3000000000
+`
+
+// Pair 实现 sql.Scanner，接收 struct 列
+type Pair struct {
+	Sensor string
+	Value  float64
+}
+
+func (p *Pair) Scan(src interface{}) error {
+	m, ok := src.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("无法扫描 %T", src)
+	}
+	p.Sensor, _ = m["c0"].(string)
+	p.Value, _ = m["c1"].(float64)
+	return nil
+}
+
+func TestDictionaryTags(t *testing.T) {
+	_, db := openServer(t, serverOptions{Data: nestedData, Server: influxdb3test.Options{DictionaryTags: true}})
+
+	reader, err := influxdb3gorm.QueryArrow(context.Background(), db.Model(&Reading{}))
+	if err != nil {
+		t.Fatalf("读取 Arrow 失败: %v", err)
+	}
+	field, _ := reader.Schema().FieldsByName("sensor")
+	reader.Release()
+	if len(field) != 1 || field[0].Type.ID() != arrow.DICTIONARY {
+		t.Fatalf("sensor 列不是字典编码: %v", field)
+	}
+
+	var rows []Reading
+	if err := db.Where("sensor = ?", "a").Order("time").Find(&rows).Error; err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Sensor != "a" || rows[1].Note != "y" {
+		t.Errorf("查询结果不正确: %+v", rows)
+	}
+
+	var sensors []string
+	if err := db.Model(&Reading{}).Distinct("sensor").Order("sensor").Pluck("sensor", &sensors).Error; err != nil {
+		t.Fatalf("Pluck 失败: %v", err)
+	}
+	if len(sensors) != 2 || sensors[0] != "a" || sensors[1] != "b" {
+		t.Errorf("Pluck 结果不正确: %v", sensors)
+	}
+
+	var maps []map[string]interface{}
+	if err := db.Model(&Reading{}).Order("time").Find(&maps).Error; err != nil {
+		t.Fatalf("查询 map 失败: %v", err)
+	}
+	if len(maps) != 3 || maps[2]["sensor"] != "b" {
+		t.Errorf("map 结果不正确: %v", maps)
+	}
+
+	for r, err := range influxdb3gorm.Stream[Reading](db.Model(&Reading{}).Where("sensor = ?", "b")) {
+		if err != nil {
+			t.Fatalf("流式读取失败: %v", err)
+		}
+		if r.Sensor != "b" || !r.Time.Equal(time.Unix(3, 0)) {
+			t.Errorf("流式读取结果不正确: %+v", r)
+		}
+	}
+}
+
+func TestListColumns(t *testing.T) {
+	_, db := openServer(t, serverOptions{Data: nestedData, Server: influxdb3test.Options{DictionaryTags: true}})
+
+	var groups []struct {
+		Sensor string
+		Values []float64 `gorm:"type:list"`
+		Notes  []string  `gorm:"type:list"`
+	}
+	err := db.Model(&Reading{}).Select("sensor, array_agg(value) AS values, array_agg(note) AS notes").
+		Group("sensor").Order("sensor").Scan(&groups).Error
+	if err != nil {
+		t.Fatalf("array_agg 失败: %v", err)
+	}
+	if len(groups) != 2 || len(groups[0].Values) != 2 || groups[0].Values[1] != 2.5 ||
+		len(groups[1].Notes) != 1 || groups[1].Notes[0] != "z" {
+		t.Errorf("列表结果不正确: %+v", groups)
+	}
+
+	// 字典编码的 tag 聚合为 []string
+	var sensors []string
+	if err := db.Model(&Reading{}).Select("array_agg(sensor)").Row().Scan(&sensors); err != nil {
+		t.Fatalf("扫描列表失败: %v", err)
+	}
+	if len(sensors) != 3 || sensors[2] != "b" {
+		t.Errorf("列表结果不正确: %v", sensors)
+	}
+}
+
+func TestStructColumns(t *testing.T) {
+	_, db := openServer(t, serverOptions{Data: nestedData, Server: influxdb3test.Options{DictionaryTags: true}})
+
+	var maps []map[string]interface{}
+	err := db.Model(&Reading{}).Select("struct(sensor, value) AS pair").Order("time").Find(&maps).Error
+	if err != nil {
+		t.Fatalf("查询 struct 失败: %v", err)
+	}
+	pair, ok := maps[0]["pair"].(map[string]interface{})
+	if len(maps) != 3 || !ok || pair["c0"] != "a" || pair["c1"] != 1.5 {
+		t.Fatalf("struct 结果不正确: %#v", maps)
+	}
+
+	var pairs []struct {
+		Pair Pair                   `gorm:"type:struct"`
+		Raw  map[string]interface{} `gorm:"type:struct"`
+	}
+	err = db.Model(&Reading{}).Select("struct(sensor, value) AS pair, struct(note) AS raw").Order("time").Scan(&pairs).Error
+	if err != nil {
+		t.Fatalf("扫描到 sql.Scanner 失败: %v", err)
+	}
+	if len(pairs) != 3 || pairs[2].Pair != (Pair{Sensor: "b", Value: 4}) || pairs[2].Raw["c0"] != "z" {
+		t.Errorf("sql.Scanner 结果不正确: %+v", pairs)
+	}
+}
+
+func TestOtherArrowColumns(t *testing.T) {
+	_, db := openServer(t, serverOptions{Data: nestedData})
+
+	// 没有专门转换的 Arrow 类型（这里是 Duration）按字符串形式返回，不会让整个查询失败
+	var maps []map[string]interface{}
+	err := db.Raw(`SELECT sensor, time - TIMESTAMP '1970-01-01T00:00:00Z' AS age FROM readings ORDER BY time`).Scan(&maps).Error
+	if err != nil {
+		t.Fatalf("查询 Duration 列失败: %v", err)
+	}
+	if len(maps) != 3 || maps[0]["sensor"] != "a" || maps[0]["age"] != "1000000000ns" {
+		t.Errorf("Duration 列的结果不正确: %#v", maps)
+	}
+
+	var ages []string
+	err = db.Raw(`SELECT time - TIMESTAMP '1970-01-01T00:00:00Z' AS age FROM readings ORDER BY time`).Pluck("age", &ages).Error
+	if err != nil || len(ages) != 3 || ages[2] != "3000000000ns" {
+		t.Errorf("Pluck Duration 列的结果不正确: %v, %v", ages, err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	influxdb3gorm "github.com/xiabin827/influxdb3-gorm-driver"
+	"github.com/xiabin827/influxdb3-gorm-driver/dialector"
+)
+
+// Sparse 部分 field 可能缺失的模型
+type Sparse struct {
+	Sensor string          `gorm:"column:sensor;type:tag"`
+	Value  float64         `gorm:"column:value"`
+	Count  *int64          `gorm:"column:count"`
+	Ratio  sql.NullFloat64 `gorm:"column:ratio"`
+	Note   string          `gorm:"column:note"`
+	Time   time.Time       `gorm:"column:time"`
+}
+
+func (Sparse) TableName() string { return "readings" }
+
+// sparseData 第一个数据点只有 note，第二个数据点只有数值 field
+const sparseData = `readings,sensor=a note="x" 1000000000
+readings,sensor=a value=1.5,count=2i,ratio=0.5 2000000000
+`
+
+func TestNullRead(t *testing.T) {
+	_, db := openServer(t, serverOptions{Data: sparseData})
+
+	var rows []Sparse
+	if err := db.Order("time").Find(&rows).Error; err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("查询结果数量不正确: %d", len(rows))
+	}
+	if r := rows[0]; r.Value != 0 || r.Count != nil || r.Ratio.Valid || r.Note != "x" {
+		t.Errorf("NULL 没有映射为零值: %+v", r)
+	}
+	if r := rows[1]; r.Value != 1.5 || r.Count == nil || *r.Count != 2 || !r.Ratio.Valid || r.Ratio.Float64 != 0.5 || r.Note != "" {
+		t.Errorf("查询结果不正确: %+v", r)
+	}
+
+	// 扫描到已有值的模型时，NULL 将字段重置为零值
+	count := int64(9)
+	row := Sparse{Value: 5, Count: &count, Ratio: sql.NullFloat64{Float64: 1, Valid: true}}
+	if err := db.Order("time").First(&row).Error; err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if row.Value != 0 || row.Count != nil || row.Ratio.Valid {
+		t.Errorf("NULL 没有重置已有的值: %+v", row)
+	}
+
+	var counts []sql.NullInt64
+	if err := db.Model(&Sparse{}).Order("time").Pluck("count", &counts).Error; err != nil {
+		t.Fatalf("Pluck 失败: %v", err)
+	}
+	if len(counts) != 2 || counts[0].Valid || counts[1].Int64 != 2 {
+		t.Errorf("Pluck 结果不正确: %v", counts)
+	}
+
+	var streamed []Sparse
+	for r, err := range influxdb3gorm.Stream[Sparse](db.Order("time")) {
+		if err != nil {
+			t.Fatalf("流式读取失败: %v", err)
+		}
+		streamed = append(streamed, r)
+	}
+	if len(streamed) != 2 || streamed[0].Count != nil || streamed[0].Ratio.Valid || *streamed[1].Count != 2 {
+		t.Errorf("流式读取结果不正确: %+v", streamed)
+	}
+}
+
+func TestNullWrite(t *testing.T) {
+	srv, db := openServer(t)
+	rows := []Sparse{
+		{Sensor: "a", Note: "x", Time: time.Unix(1, 0)},
+		{Sensor: "a", Count: new(int64), Ratio: sql.NullFloat64{Float64: 0, Valid: true}, Time: time.Unix(2, 0)},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	// nil 指针和无效的 sql.Null* 不写入，默认写入非指针字段的零值
+	want := []string{
+		`readings,sensor=a note="x",value=0 1000000000`,
+		`readings,sensor=a count=0i,note="",ratio=0,value=0 2000000000`,
+	}
+	lines := srv.Lines("readings")
+	if len(lines) != 2 || lines[0] != want[0] || lines[1] != want[1] {
+		t.Errorf("写入的行协议不正确\n得到: %q\n期望: %q", lines, want)
+	}
+}
+
+func TestOmitZeroFields(t *testing.T) {
+	srv, db := openServer(t, serverOptions{Config: func(c *dialector.Config) { c.OmitZeroFields = true }})
+	rows := []Sparse{
+		{Sensor: "a", Note: "x", Time: time.Unix(1, 0)},
+		{Sensor: "a", Value: 1.5, Count: new(int64), Time: time.Unix(2, 0)},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	// 零值的非指针字段不写入，非 nil 指针指向的零值仍然写入
+	want := []string{
+		`readings,sensor=a note="x" 1000000000`,
+		`readings,sensor=a count=0i,value=1.5 2000000000`,
+	}
+	lines := srv.Lines("readings")
+	if len(lines) != 2 || lines[0] != want[0] || lines[1] != want[1] {
+		t.Fatalf("写入的行协议不正确\n得到: %q\n期望: %q", lines, want)
+	}
+
+	// 查询得到的零值在 Save 时不会覆盖缺失的 field
+	var row Sparse
+	if err := db.Order("time").First(&row).Error; err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	row.Note = "y"
+	if err := db.Save(&row).Error; err != nil {
+		t.Fatalf("Save 失败: %v", err)
+	}
+	if lines := srv.Lines("readings"); lines[0] != `readings,sensor=a note="y" 1000000000` {
+		t.Errorf("Save 后的行协议不正确: %q", lines)
+	}
+}
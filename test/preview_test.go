@@ -0,0 +1,138 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	influxdb3gorm "github.com/xiabin827/influxdb3-gorm-driver"
+	"github.com/xiabin827/influxdb3-gorm-driver/dialector"
+	"gorm.io/gorm"
+)
+
+// Reading 用于预览写入的模型
+type Reading struct {
+	Sensor string    `gorm:"column:sensor;type:tag"`
+	Value  float64   `gorm:"column:value"`
+	Count  int       `gorm:"column:count"`
+	Note   string    `gorm:"column:note"`
+	Time   time.Time `gorm:"column:time"`
+}
+
+// openDryRun 打开不连接服务端的 DryRun 会话
+func openDryRun(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(influxdb3gorm.New(dialector.Config{
+		Database: "test",
+		Conn:     &dialector.InfluxDBConnPool{},
+	}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	return db.Session(&gorm.Session{DryRun: true})
+}
+
+func TestPreviewCreate(t *testing.T) {
+	db := openDryRun(t)
+	ts := time.Unix(1700000000, 0)
+	rows := []Reading{
+		{Sensor: "s 1", Value: 1.5, Count: 2, Note: `a "b"`, Time: ts},
+		{Sensor: "s2", Value: 3, Count: 4, Note: "c", Time: ts.Add(time.Second)},
+	}
+
+	tx := db.Create(&rows)
+	if tx.Error != nil {
+		t.Fatalf("DryRun 创建失败: %v", tx.Error)
+	}
+	preview, ok := dialector.Preview(tx)
+	if !ok {
+		t.Fatalf("没有生成写入预览")
+	}
+
+	want := `readings,sensor=s\ 1 count=2i,note="a \"b\"",value=1.5 1700000000000000000` + "\n" +
+		`readings,sensor=s2 count=4i,note="c",value=3 1700000001000000000` + "\n"
+	if preview.LineProtocol != want {
+		t.Errorf("行协议不正确\n得到: %q\n期望: %q", preview.LineProtocol, want)
+	}
+	if preview.Table != "readings" || len(preview.Points) != 2 {
+		t.Errorf("预览信息不正确: 表 %s，数据点 %d", preview.Table, len(preview.Points))
+	}
+	if got := tx.Statement.SQL.String(); got != strings.TrimSuffix(want, "\n") {
+		t.Errorf("Statement.SQL 应为行协议，得到: %q", got)
+	}
+	if tx.RowsAffected != 0 {
+		t.Errorf("DryRun 不应写入数据，RowsAffected: %d", tx.RowsAffected)
+	}
+}
+
+func TestPreviewToSQL(t *testing.T) {
+	db := openDryRun(t)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Create(&Reading{Sensor: "a", Value: 1, Time: time.Unix(1, 0)})
+	})
+	want := `readings,sensor=a count=0i,note="",value=1 1000000000`
+	if sql != want {
+		t.Errorf("ToSQL 结果不正确\n得到: %q\n期望: %q", sql, want)
+	}
+}
+
+func TestPreviewUpdate(t *testing.T) {
+	db := openDryRun(t)
+	reading := Reading{Sensor: "a", Value: 1, Time: time.Unix(2, 0)}
+
+	tx := db.Model(&reading).Update("value", 2.5)
+	if tx.Error != nil {
+		t.Fatalf("DryRun 更新失败: %v", tx.Error)
+	}
+	preview, ok := dialector.Preview(tx)
+	if !ok {
+		t.Fatalf("没有生成写入预览")
+	}
+	if want := "readings,sensor=a value=2.5 2000000000\n"; preview.LineProtocol != want {
+		t.Errorf("行协议不正确\n得到: %q\n期望: %q", preview.LineProtocol, want)
+	}
+	if reading.Value != 1 {
+		t.Errorf("DryRun 不应修改模型，得到 %v", reading.Value)
+	}
+}
+
+func TestPreviewUpdateWhere(t *testing.T) {
+	db := openDryRun(t)
+	tx := db.Model(&Reading{}).Where("sensor = ?", "a").Update("value", 2.5)
+	if tx.Error != nil {
+		t.Fatalf("DryRun 更新失败: %v", tx.Error)
+	}
+	preview, ok := dialector.Preview(tx)
+	if !ok {
+		t.Fatalf("没有生成写入预览")
+	}
+	if preview.LineProtocol != "" {
+		t.Errorf("带条件的更新不应预览行协议，得到: %q", preview.LineProtocol)
+	}
+	if !strings.Contains(preview.SQL, `FROM "readings"`) || !strings.Contains(preview.SQL, `sensor = 'a'`) {
+		t.Errorf("查询语句不正确: %s", preview.SQL)
+	}
+}
+
+func TestPreviewSaveWithoutTimestamp(t *testing.T) {
+	db := openDryRun(t)
+	tx := db.Save(&Reading{Sensor: "a", Value: 1})
+	if tx.Error != nil {
+		t.Fatalf("DryRun 保存失败: %v", tx.Error)
+	}
+	preview, ok := dialector.Preview(tx)
+	if !ok {
+		t.Fatalf("没有生成写入预览")
+	}
+	if want := "readings,sensor=a count=0i,note=\"\",value=1\n"; preview.LineProtocol != want {
+		t.Errorf("行协议不正确\n得到: %q\n期望: %q", preview.LineProtocol, want)
+	}
+}
+
+func TestPreviewEncodeError(t *testing.T) {
+	db := openDryRun(t)
+	err := db.Create(&Reading{Sensor: "a\nb", Value: 1}).Error
+	if err == nil || !strings.Contains(err.Error(), "控制字符") {
+		t.Errorf("期望返回编码错误，得到: %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestQuoteIdentifier(t *testing.T) {
+	db := openDryRun(t)
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"普通标识符", "cpu", `"cpu"`},
+		{"限定名", "iox.cpu", `"iox"."cpu"`},
+		{"三段限定名", "public.iox.cpu", `"public"."iox"."cpu"`},
+		{"通配符", "cpu.*", `"cpu".*`},
+		{"转义双引号", `my"table`, `"my""table"`},
+		{"注入", `cpu" WHERE 1=1 --`, `"cpu"" WHERE 1=1 --"`},
+		{"已加引号", `"cpu.usage"`, `"cpu.usage"`},
+		{"已加引号的限定名", `iox."cpu.usage"`, `"iox"."cpu.usage"`},
+		{"已转义的双引号", `"a""b"`, `"a""b"`},
+		{"不完整的引号", `"cpu`, `"""cpu"`},
+		{"引号中间的点号", `a"b.c`, `"a""b.c"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := db.Statement.Quote(tt.in); got != tt.want {
+				t.Errorf("Quote(%q) = %s，期望 %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	influxdb3gorm "github.com/xiabin827/influxdb3-gorm-driver"
+)
+
+// Level 实现 driver.Valuer 和 sql.Scanner，Value 返回 int64
+type Level uint8
+
+func (l Level) Value() (driver.Value, error) {
+	if l > 100 {
+		return nil, errors.New("level 超出范围")
+	}
+	return int64(l), nil
+}
+
+func (l *Level) Scan(src interface{}) error {
+	v, ok := src.(uint64)
+	if !ok {
+		return fmt.Errorf("无法扫描 %T", src)
+	}
+	*l = Level(v)
+	return nil
+}
+
+// Celsius 以 float 存储的结构体
+type Celsius struct {
+	Degrees float64
+}
+
+func (c Celsius) Value() (driver.Value, error) {
+	return c.Degrees, nil
+}
+
+func (c *Celsius) Scan(src interface{}) error {
+	v, ok := src.(float64)
+	if !ok {
+		return fmt.Errorf("无法扫描 %T", src)
+	}
+	c.Degrees = v
+	return nil
+}
+
+// Event 带有 serializer 和自定义类型字段的模型
+type Event struct {
+	Host  string                 `gorm:"column:host;type:tag"`
+	Meta  map[string]interface{} `gorm:"column:meta;serializer:json"`
+	Level Level                  `gorm:"column:level;type:uint"`
+	Temp  Celsius                `gorm:"column:temp"`
+	Note  sql.NullString         `gorm:"column:note"`
+	Time  time.Time              `gorm:"column:time"`
+}
+
+func TestSerializerWrite(t *testing.T) {
+	srv, db := openServer(t)
+	events := []Event{
+		{Host: "a", Meta: map[string]interface{}{"k": "v"}, Level: 3, Temp: Celsius{21.5},
+			Note: sql.NullString{String: "hi", Valid: true}, Time: time.Unix(1, 0)},
+		// nil 的 map 和无效的 NullString 不写入
+		{Host: "a", Level: 4, Temp: Celsius{20}, Time: time.Unix(2, 0)},
+	}
+	if err := db.Create(&events).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	want := []string{
+		`events,host=a level=3u,meta="{\"k\":\"v\"}",note="hi",temp=21.5 1000000000`,
+		`events,host=a level=4u,temp=20 2000000000`,
+	}
+	if lines := srv.Lines("events"); strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("写入的行协议不正确\n得到: %q\n期望: %q", lines, want)
+	}
+
+	// Valuer 的错误原样返回
+	err := db.Create(&Event{Host: "a", Level: 200, Time: time.Unix(3, 0)}).Error
+	if err == nil || !strings.Contains(err.Error(), "level 超出范围") {
+		t.Errorf("期望 Valuer 的错误，得到: %v", err)
+	}
+}
+
+func TestSerializerRead(t *testing.T) {
+	srv, db := openServer(t)
+	err := srv.WriteLineProtocol(`events,host=a level=3u,meta="{\"k\":\"v\",\"n\":1}",note="hi",temp=21.5 1000000000` + "\n")
+	if err != nil {
+		t.Fatalf("准备测试数据失败: %v", err)
+	}
+
+	check := func(name string, e Event) {
+		t.Helper()
+		if e.Meta["k"] != "v" || e.Meta["n"] != float64(1) || e.Level != 3 || e.Temp.Degrees != 21.5 ||
+			e.Note.String != "hi" || !e.Time.Equal(time.Unix(1, 0)) {
+			t.Errorf("%s 结果不正确: %+v", name, e)
+		}
+	}
+
+	var found []Event
+	if err := db.Find(&found).Error; err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("查询结果数量不正确: %d", len(found))
+	}
+	check("查询", found[0])
+
+	for e, err := range influxdb3gorm.Stream[Event](db.Model(&Event{})) {
+		if err != nil {
+			t.Fatalf("流式读取失败: %v", err)
+		}
+		check("流式读取", e)
+	}
+}
+
+func TestSerializerUpdate(t *testing.T) {
+	srv, db := openServer(t)
+	event := Event{Host: "a", Meta: map[string]interface{}{"k": "v"}, Level: 3, Time: time.Unix(1, 0)}
+	if err := db.Create(&event).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	err := db.Model(&event).Updates(map[string]interface{}{
+		"meta":  map[string]interface{}{"k": "w"},
+		"level": 5,
+	}).Error
+	if err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+	want := `events,host=a level=5u,meta="{\"k\":\"w\"}",temp=0 1000000000`
+	if lines := srv.Lines("events"); len(lines) != 1 || lines[0] != want {
+		t.Errorf("更新后的行协议不正确\n得到: %q\n期望: %q", lines, want)
+	}
+}
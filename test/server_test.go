@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	influxdb3gorm "github.com/xiabin827/influxdb3-gorm-driver"
+	"github.com/xiabin827/influxdb3-gorm-driver/dialector"
+	"github.com/xiabin827/influxdb3-gorm-driver/influxdb3test"
+	"gorm.io/gorm"
+)
+
+// serverOptions 测试服务端的数据和连接配置
+type serverOptions struct {
+	Data   string                  // 启动后写入的行协议
+	Server influxdb3test.Options   // 服务端选项
+	Config func(*dialector.Config) // 打开连接前修改配置
+}
+
+// openServer 启动测试服务端，写入 opts 中的数据并打开连接
+func openServer(t *testing.T, opts ...serverOptions) (*influxdb3test.Server, *gorm.DB) {
+	t.Helper()
+	var opt serverOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	srv := influxdb3test.NewServer(opt.Server)
+	t.Cleanup(srv.Close)
+	if opt.Data != "" {
+		if err := srv.WriteLineProtocol(opt.Data); err != nil {
+			t.Fatalf("准备测试数据失败: %v", err)
+		}
+	}
+	config := srv.Config()
+	if opt.Config != nil {
+		opt.Config(&config)
+	}
+	db, err := gorm.Open(influxdb3gorm.New(config), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	return srv, db
+}
+
+func TestServerCreateAndFind(t *testing.T) {
+	srv, db := openServer(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := []Reading{
+		{Sensor: "a", Value: 1.5, Count: 1, Note: "x", Time: base},
+		{Sensor: "b", Value: 2.5, Count: 2, Note: "y", Time: base.Add(time.Minute)},
+		{Sensor: "a", Value: 3.5, Count: 3, Note: "z", Time: base.Add(2 * time.Minute)},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	writes := srv.Writes()
+	if len(writes) != 1 || writes[0].Database != srv.Database || strings.Count(writes[0].Body, "\n") != 3 {
+		t.Fatalf("服务端收到的写入不正确: %+v", writes)
+	}
+
+	var found []Reading
+	if err := db.Where("sensor = ?", "a").Order("time DESC").Find(&found).Error; err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(found) != 2 || found[0].Value != 3.5 || found[1].Value != 1.5 {
+		t.Fatalf("查询结果不正确: %+v", found)
+	}
+	if !found[0].Time.Equal(rows[2].Time) || found[0].Note != "z" || found[0].Count != 3 {
+		t.Errorf("字段值不正确: %+v", found[0])
+	}
+
+	var ranged []Reading
+	if err := db.Scopes(influxdb3gorm.Between(base.Add(time.Minute), base.Add(time.Hour))).Find(&ranged).Error; err != nil {
+		t.Fatalf("按时间范围查询失败: %v", err)
+	}
+	if len(ranged) != 2 {
+		t.Errorf("期望 2 条记录，实际 %d 条", len(ranged))
+	}
+
+	queries := srv.Queries()
+	if last := queries[len(queries)-1]; !strings.Contains(last.SQL, `FROM "readings"`) || last.QueryType != "sql" {
+		t.Errorf("服务端收到的查询不正确: %+v", last)
+	}
+}
+
+func TestServerTimeRangeScopes(t *testing.T) {
+	_, db := openServer(t)
+	now := time.Now()
+	rows := []Reading{
+		{Sensor: "old", Time: now.Add(-2 * time.Hour)},
+		{Sensor: "recent", Time: now.Add(-10 * time.Minute)},
+		{Sensor: "future", Time: now.Add(time.Hour)},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		scope func(*gorm.DB) *gorm.DB
+		want  []string
+	}{
+		{"Between", influxdb3gorm.Between(now.Add(-3*time.Hour), now.Add(-10*time.Minute)), []string{"old"}},
+		{"Since", influxdb3gorm.Since(time.Hour), []string{"recent", "future"}},
+		{"Last", influxdb3gorm.Last(time.Hour), []string{"recent"}},
+	} {
+		var sensors []string
+		if err := db.Model(&Reading{}).Scopes(tt.scope).Order("time").Pluck("sensor", &sensors).Error; err != nil {
+			t.Fatalf("%s 查询失败: %v", tt.name, err)
+		}
+		if strings.Join(sensors, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s 结果不正确: %v，期望 %v", tt.name, sensors, tt.want)
+		}
+	}
+}
+
+func TestServerGapFillMissingTimeRange(t *testing.T) {
+	srv, db := openServer(t, serverOptions{Data: cacheData})
+	sent := len(srv.Queries())
+
+	// 缺少时间范围时在发送查询前返回错误，不会得到服务端的规划错误
+	for name, scope := range map[string]func(*gorm.DB) *gorm.DB{
+		"没有时间条件": func(tx *gorm.DB) *gorm.DB { return tx },
+		"Since":  influxdb3gorm.Since(time.Hour),
+	} {
+		var rows []map[string]interface{}
+		err := db.Model(&Reading{}).Scopes(scope, influxdb3gorm.GapFill(time.Second, "time")).
+			Select("sensor", influxdb3gorm.Interpolate("avg(value)")).Group("sensor").Find(&rows).Error
+		if !errors.Is(err, dialector.ErrMissingTimeRange) {
+			t.Errorf("%s: 期望 ErrMissingTimeRange，得到: %v", name, err)
+		}
+	}
+	if queries := srv.Queries(); len(queries) != sent {
+		t.Errorf("缺少时间范围时不应发送查询: %+v", queries[sent:])
+	}
+}
+
+func TestServerUpdateOverwritesPoint(t *testing.T) {
+	srv, db := openServer(t)
+	reading := Reading{Sensor: "a", Value: 1, Count: 1, Note: "x", Time: time.Unix(100, 0)}
+	if err := db.Create(&reading).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if err := db.Model(&reading).Update("value", 9.5).Error; err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+
+	lines := srv.Lines("readings")
+	want := `readings,sensor=a count=1i,note="x",value=9.5 100000000000`
+	if len(lines) != 1 || lines[0] != want {
+		t.Errorf("更新后的数据不正确\n得到: %q\n期望: %q", lines, want)
+	}
+}
+
+func TestServerUpdateWhere(t *testing.T) {
+	srv, db := openServer(t)
+	if err := srv.WriteLineProtocol("readings,sensor=a value=1 1\nreadings,sensor=a value=2 2\nreadings,sensor=b value=3 3\n"); err != nil {
+		t.Fatalf("准备测试数据失败: %v", err)
+	}
+
+	result := db.Model(&Reading{}).Where("sensor = ?", "a").Update("value", 7.0)
+	if result.Error != nil {
+		t.Fatalf("更新失败: %v", result.Error)
+	}
+	if result.RowsAffected != 2 {
+		t.Errorf("期望重写 2 个数据点，实际 %d 个", result.RowsAffected)
+	}
+
+	want := []string{"readings,sensor=a value=7 1", "readings,sensor=a value=7 2", "readings,sensor=b value=3 3"}
+	if got := srv.Lines("readings"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("更新后的数据不正确\n得到: %q\n期望: %q", got, want)
+	}
+}
+
+// Metric 只声明动态 tag 的模型，host 不是 `type:tag` 字段
+type Metric struct {
+	Value float64           `gorm:"column:value"`
+	Time  time.Time         `gorm:"column:time"`
+	Tags  map[string]string `gorm:"-;influx:tags"`
+}
+
+func (Metric) TableName() string { return "events" }
+
+func TestServerUpdateWhereUndeclaredTag(t *testing.T) {
+	srv, db := openServer(t, serverOptions{Data: "events,host=a value=1 1000\n"})
+
+	// 重写的数据点带有结果中全部的 tag，覆盖原数据点而不是写入新的序列
+	result := db.Model(&Metric{}).Where("value = ?", 1).Update("value", 5)
+	if result.Error != nil {
+		t.Fatalf("更新失败: %v", result.Error)
+	}
+	if result.RowsAffected != 1 {
+		t.Errorf("期望重写 1 个数据点，实际 %d 个", result.RowsAffected)
+	}
+	want := "events,host=a value=5 1000"
+	if got := srv.Lines("events"); len(got) != 1 || got[0] != want {
+		t.Errorf("更新后的数据不正确\n得到: %q\n期望: %q", got, want)
+	}
+}
+
+// Counted 没有声明 host tag 的模型
+type Counted struct {
+	Value float64   `gorm:"column:value"`
+	Time  time.Time `gorm:"column:time"`
+}
+
+func (Counted) TableName() string { return "events" }
+
+func TestServerSaveUndeclaredTag(t *testing.T) {
+	srv, db := openServer(t, serverOptions{Data: "events,host=a value=1 1000\n"})
+
+	// 模型中没有 host，按查询到的数据点的完整 tag 重写，原数据点被修改
+	var counted Counted
+	if err := db.First(&counted).Error; err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	counted.Value = 5
+	if err := db.Save(&counted).Error; err != nil {
+		t.Fatalf("Save 失败: %v", err)
+	}
+	if got := srv.Lines("events"); len(got) != 1 || got[0] != "events,host=a value=5 1000" {
+		t.Errorf("Save 后的数据不正确: %q", got)
+	}
+
+	result := db.Model(&counted).Update("value", 6)
+	if result.Error != nil || result.RowsAffected != 1 {
+		t.Fatalf("更新失败: %v, RowsAffected %d", result.Error, result.RowsAffected)
+	}
+	if got := srv.Lines("events"); len(got) != 1 || got[0] != "events,host=a value=6 1000" {
+		t.Errorf("更新后的数据不正确: %q", got)
+	}
+
+	// 没有匹配的数据点时 Save 改为创建
+	if err := db.Save(&Counted{Value: 7, Time: time.Unix(0, 2000)}).Error; err != nil {
+		t.Fatalf("Save 失败: %v", err)
+	}
+	if got := srv.Lines("events"); len(got) != 2 || got[1] != "events value=7 2000" {
+		t.Errorf("Save 新数据点的结果不正确: %q", got)
+	}
+}
+
+func TestServerInformationSchema(t *testing.T) {
+	srv, db := openServer(t)
+	if err := srv.WriteLineProtocol("cpu,host=a usage=1.5,cores=4i 1\nmem,host=a used=10u 1\n"); err != nil {
+		t.Fatalf("准备测试数据失败: %v", err)
+	}
+
+	var tables []string
+	err := db.Raw("SELECT table_name FROM information_schema.tables WHERE table_schema = 'iox' ORDER BY table_name").
+		Scan(&tables).Error
+	if err != nil {
+		t.Fatalf("查询表失败: %v", err)
+	}
+	if strings.Join(tables, ",") != "cpu,mem" {
+		t.Errorf("表不正确: %v", tables)
+	}
+
+	var columns []struct {
+		ColumnName string
+		DataType   string
+	}
+	err = db.Raw("SELECT column_name, data_type FROM information_schema.columns WHERE table_name = ?", "cpu").
+		Scan(&columns).Error
+	if err != nil {
+		t.Fatalf("查询列失败: %v", err)
+	}
+	var got []string
+	for _, c := range columns {
+		got = append(got, c.ColumnName+":"+c.DataType)
+	}
+	want := "cores:Int64,host:Dictionary(Int32, Utf8),time:Timestamp(Nanosecond, None),usage:Float64"
+	if strings.Join(got, ",") != want {
+		t.Errorf("列不正确\n得到: %s\n期望: %s", strings.Join(got, ","), want)
+	}
+}
+
+func TestServerAggregates(t *testing.T) {
+	srv, db := openServer(t)
+	if err := srv.WriteLineProtocol("readings,sensor=a value=1,count=1i 1\nreadings,sensor=a value=2,count=2i 2\nreadings,sensor=b value=4,count=3i 3\n"); err != nil {
+		t.Fatalf("准备测试数据失败: %v", err)
+	}
+
+	var count int64
+	if err := db.Model(&Reading{}).Where("sensor = ?", "a").Count(&count).Error; err != nil {
+		t.Fatalf("Count 失败: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Count 结果不正确: %d", count)
+	}
+
+	var totals []struct {
+		Sensor string
+		Total  float64
+	}
+	err := db.Model(&Reading{}).Select("sensor, sum(value) AS total").Group("sensor").Order("sensor").Scan(&totals).Error
+	if err != nil {
+		t.Fatalf("分组聚合失败: %v", err)
+	}
+	if len(totals) != 2 || totals[0].Total != 3 || totals[1].Total != 4 {
+		t.Errorf("分组聚合结果不正确: %+v", totals)
+	}
+}
+
+func TestServerPartialWrite(t *testing.T) {
+	// count 列第一次以 float 写入，之后的整数写入会被拒绝，零值不写入以免第一个数据点也被拒绝
+	const data = "readings,sensor=a count=1.5 2\n"
+	rows := func() []Reading {
+		return []Reading{
+			{Sensor: "a", Value: 1, Time: time.Unix(10, 0)},
+			{Sensor: "a", Value: 2, Count: 1, Time: time.Unix(11, 0)},
+		}
+	}
+
+	t.Run("拒绝", func(t *testing.T) {
+		srv, db := openServer(t, serverOptions{Data: data, Config: func(c *dialector.Config) { c.OmitZeroFields = true }})
+		result := db.Create(rows())
+		var partialErr *dialector.PartialWriteError
+		if !errors.As(result.Error, &partialErr) {
+			t.Fatalf("期望部分写入错误，得到: %v", result.Error)
+		}
+		if len(partialErr.Lines) != 1 || partialErr.Lines[0].Index != 1 || !strings.Contains(partialErr.Lines[0].Message, "count") {
+			t.Errorf("被拒绝的行不正确: %+v", partialErr.Lines)
+		}
+		if writes := srv.Writes(); len(writes) != 1 || writes[0].AcceptPartial {
+			t.Errorf("写入请求应设置 accept_partial=false: %+v", writes)
+		}
+		// 整个批次都不会写入
+		if lines := srv.Lines("readings"); len(lines) != 1 || result.RowsAffected != 0 {
+			t.Errorf("批次中的数据点不应写入: %q, RowsAffected=%d", lines, result.RowsAffected)
+		}
+	})
+
+	t.Run("接受", func(t *testing.T) {
+		var reported []*dialector.PartialWriteError
+		srv, db := openServer(t, serverOptions{Data: data, Config: func(c *dialector.Config) {
+			c.OmitZeroFields = true
+			c.AcceptPartial = true
+			c.OnPartialWrite = func(ctx context.Context, err *dialector.PartialWriteError) {
+				reported = append(reported, err)
+			}
+		}})
+		result := db.Create(rows())
+		if result.Error != nil {
+			t.Fatalf("开启 AcceptPartial 后不应返回错误: %v", result.Error)
+		}
+		if writes := srv.Writes(); len(writes) != 1 || !writes[0].AcceptPartial {
+			t.Errorf("写入请求应设置 accept_partial=true: %+v", writes)
+		}
+		want := []string{"readings,sensor=a count=1.5 2", "readings,sensor=a value=1 10000000000"}
+		if lines := srv.Lines("readings"); strings.Join(lines, "\n") != strings.Join(want, "\n") || result.RowsAffected != 1 {
+			t.Errorf("其余数据点应写入\n得到: %q, RowsAffected=%d\n期望: %q", lines, result.RowsAffected, want)
+		}
+		if len(reported) != 1 || len(reported[0].Lines) != 1 || reported[0].Lines[0].Index != 1 ||
+			!strings.HasPrefix(reported[0].Lines[0].Text, "readings,sensor=a count=1i") {
+			t.Errorf("OnPartialWrite 收到的行不正确: %+v", reported)
+		}
+	})
+
+	t.Run("分批", func(t *testing.T) {
+		batch := func() []Reading {
+			return append([]Reading{{Sensor: "b", Value: 3, Time: time.Unix(12, 0)}}, rows()...)
+		}
+		srv, _ := openServer(t, serverOptions{Data: data})
+		config := srv.Config()
+		config.OmitZeroFields = true
+
+		// 全局的 CreateBatchSize 由驱动分批，下标按整个切片计算
+		db, err := gorm.Open(influxdb3gorm.New(config), &gorm.Config{CreateBatchSize: 2})
+		if err != nil {
+			t.Fatalf("打开数据库失败: %v", err)
+		}
+		var partialErr *dialector.PartialWriteError
+		if err := db.Create(batch()).Error; !errors.As(err, &partialErr) ||
+			len(partialErr.Lines) != 1 || partialErr.Lines[0].Index != 2 || partialErr.Lines[0].Line != 3 {
+			t.Errorf("全局分批时被拒绝的行不正确: %v", err)
+		}
+
+		// CreateInBatches 在进入驱动前由 GORM 切分，下标按 GORM 的批次计算
+		db, err = gorm.Open(influxdb3gorm.New(config), &gorm.Config{})
+		if err != nil {
+			t.Fatalf("打开数据库失败: %v", err)
+		}
+		if err := db.CreateInBatches(batch(), 2).Error; !errors.As(err, &partialErr) ||
+			len(partialErr.Lines) != 1 || partialErr.Lines[0].Index != 0 || partialErr.Lines[0].Line != 1 {
+			t.Errorf("CreateInBatches 时被拒绝的行不正确: %v", err)
+		}
+	})
+}
+
+func TestServerErrors(t *testing.T) {
+	srv, db := openServer(t)
+
+	var rows []Reading
+	if err := db.Find(&rows).Error; !errors.Is(err, dialector.ErrTableNotFound) {
+		t.Errorf("期望 ErrTableNotFound，得到: %v", err)
+	}
+
+	config := srv.Config()
+	config.Token = "wrong"
+	if _, err := gorm.Open(influxdb3gorm.New(config), &gorm.Config{}); err == nil || !strings.Contains(err.Error(), "Unauthenticated") {
+		t.Errorf("期望认证失败，得到: %v", err)
+	}
+}
+
+func TestServerDeleteTable(t *testing.T) {
+	srv, db := openServer(t)
+	if err := srv.WriteLineProtocol("readings,sensor=a value=1 1\n"); err != nil {
+		t.Fatalf("准备测试数据失败: %v", err)
+	}
+	if err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&Reading{}).Error; err != nil {
+		t.Fatalf("删除失败: %v", err)
+	}
+	if lines := srv.Lines("readings"); len(lines) != 0 {
+		t.Errorf("表没有被删除: %v", lines)
+	}
+}
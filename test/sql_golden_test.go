@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	influxdb3gorm "github.com/xiabin827/influxdb3-gorm-driver"
+	"github.com/xiabin827/influxdb3-gorm-driver/dialector"
+	"gorm.io/gorm"
+)
+
+// update 为 true 时用生成的 SQL 覆盖 golden 文件：go test ./test -run TestSQLGolden -update
+var update = flag.Bool("update", false, "更新 golden 文件")
+
+// QuotedName 表名中带有双引号的模型
+type QuotedName struct {
+	Value float64   `gorm:"column:value"`
+	Time  time.Time `gorm:"column:time"`
+}
+
+func (QuotedName) TableName() string { return `my"table` }
+
+func TestSQLGolden(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		query func(tx *gorm.DB) *gorm.DB
+	}{
+		{"find", func(tx *gorm.DB) *gorm.DB {
+			return tx.Find(&[]Reading{})
+		}},
+		{"where_string", func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("sensor = ? AND value > ?", "it's", 1.5).Find(&[]Reading{})
+		}},
+		{"where_struct", func(tx *gorm.DB) *gorm.DB {
+			return tx.Where(&Reading{Sensor: "a", Count: 3}).Find(&[]Reading{})
+		}},
+		{"where_map", func(tx *gorm.DB) *gorm.DB {
+			return tx.Where(map[string]interface{}{"sensor": "a"}).Find(&[]Reading{})
+		}},
+		{"where_arg_question", func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("note = ? AND sensor = ?", "what?", "a").Find(&[]Reading{})
+		}},
+		{"where_literal_question", func(tx *gorm.DB) *gorm.DB {
+			return tx.Where(`note = 'x?' AND "what?" = ? AND sensor = ?`, "it's '?'", "a`b").Find(&[]Reading{})
+		}},
+		{"where_in", func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("sensor IN ?", []string{"a", "b"}).Find(&[]Reading{})
+		}},
+		{"where_time", func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("time >= ?", start).Find(&[]Reading{})
+		}},
+		{"where_or_not", func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("sensor = ?", "a").Or("sensor = ?", "b").Not("count = ?", 0).Find(&[]Reading{})
+		}},
+		{"order", func(tx *gorm.DB) *gorm.DB {
+			return tx.Order("time DESC").Order("sensor").Find(&[]Reading{})
+		}},
+		{"limit", func(tx *gorm.DB) *gorm.DB {
+			return tx.Limit(10).Find(&[]Reading{})
+		}},
+		{"limit_offset", func(tx *gorm.DB) *gorm.DB {
+			return tx.Limit(10).Offset(20).Find(&[]Reading{})
+		}},
+		{"offset", func(tx *gorm.DB) *gorm.DB {
+			return tx.Offset(5).Find(&[]Reading{})
+		}},
+		{"limit_negative", func(tx *gorm.DB) *gorm.DB {
+			return tx.Limit(-1).Find(&[]Reading{})
+		}},
+		{"first", func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("sensor = ?", "a").First(&Reading{})
+		}},
+		{"last", func(tx *gorm.DB) *gorm.DB {
+			return tx.Last(&Reading{})
+		}},
+		{"select", func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&Reading{}).Select("sensor", "value").Find(&[]Reading{})
+		}},
+		{"distinct", func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&Reading{}).Distinct("sensor").Find(&[]string{})
+		}},
+		{"group_having", func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&Reading{}).Select("sensor, avg(value) AS avg").
+				Group("sensor").Having("avg(value) > ?", 10).Find(&[]map[string]interface{}{})
+		}},
+		{"pluck", func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&Reading{}).Where("count > ?", 1).Pluck("sensor", &[]string{})
+		}},
+		{"count", func(tx *gorm.DB) *gorm.DB {
+			var count int64
+			return tx.Model(&Reading{}).Count(&count)
+		}},
+		{"count_where", func(tx *gorm.DB) *gorm.DB {
+			var count int64
+			return tx.Model(&Reading{}).Where("sensor = ?", "a").Count(&count)
+		}},
+		{"joins", func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&Reading{}).Select("readings.sensor, s.location").
+				Joins("JOIN sensors s ON s.sensor = readings.sensor AND s.active = ?", true).
+				Find(&[]map[string]interface{}{})
+		}},
+		{"between", func(tx *gorm.DB) *gorm.DB {
+			return tx.Scopes(influxdb3gorm.Between(start, end)).Find(&[]Reading{})
+		}},
+		{"since", func(tx *gorm.DB) *gorm.DB {
+			return tx.Scopes(influxdb3gorm.Since(90 * time.Minute)).Find(&[]Reading{})
+		}},
+		{"last_duration", func(tx *gorm.DB) *gorm.DB {
+			return tx.Scopes(influxdb3gorm.Last(1500 * time.Millisecond)).Find(&[]Reading{})
+		}},
+		{"time_bucket", func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&Reading{}).Select("avg(value) AS value").
+				Scopes(influxdb3gorm.Between(start, end), influxdb3gorm.TimeBucket(5*time.Minute, "time")).
+				Find(&[]map[string]interface{}{})
+		}},
+		{"time_bucket_distinct", func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&Reading{}).Distinct("sensor").
+				Scopes(influxdb3gorm.Between(start, end), influxdb3gorm.TimeBucket(time.Minute, "time")).
+				Find(&[]map[string]interface{}{})
+		}},
+		{"time_bucket_options", func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&Reading{}).Select("sensor, max(value) AS value").Group("sensor").
+				Scopes(influxdb3gorm.TimeBucket(24*time.Hour, "time", dialector.TimeBucketOptions{
+					Location: time.FixedZone("UTC+8", 8*3600),
+					Alias:    "day",
+				})).
+				Find(&[]map[string]interface{}{})
+		}},
+		{"gap_fill_interpolate", func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&Reading{}).Select("sensor, ? AS value", influxdb3gorm.Interpolate("avg(value)")).
+				Where("time >= ? AND time < ?", start, end).Group("sensor").
+				Scopes(influxdb3gorm.GapFill(10*time.Minute, "time")).
+				Find(&[]map[string]interface{}{})
+		}},
+		{"gap_fill_locf", func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&Reading{}).Select("? AS value", influxdb3gorm.LOCF("last_value(value ORDER BY time)")).
+				Scopes(influxdb3gorm.Between(start, end), influxdb3gorm.GapFill(500*time.Millisecond, "time")).
+				Find(&[]map[string]interface{}{})
+		}},
+		{"quoted_table", func(tx *gorm.DB) *gorm.DB {
+			return tx.Find(&[]QuotedName{})
+		}},
+		{"qualified_table", func(tx *gorm.DB) *gorm.DB {
+			return tx.Table("iox.readings").Select("readings.sensor", "value").Find(&[]map[string]interface{}{})
+		}},
+		{"ident", func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&Reading{}).Select("?", influxdb3gorm.Ident("usage.idle")).
+				Where("? > ?", influxdb3gorm.Ident("readings", "usage.idle"), 90).Find(&[]map[string]interface{}{})
+		}},
+	}
+
+	db := openDryRun(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := db.ToSQL(tt.query) + "\n"
+			path := filepath.Join("testdata", "sql", tt.name+".sql")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatalf("创建目录失败: %v", err)
+				}
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatalf("写入 golden 文件失败: %v", err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("读取 golden 文件失败: %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("生成的 SQL 与 %s 不一致\n得到: %s期望: %s", path, got, want)
+			}
+		})
+	}
+}
+
+func TestSQLGoldenNoVars(t *testing.T) {
+	// 没有参数的语句原样返回
+	db := openDryRun(t)
+	got := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Raw(`SELECT * FROM "readings" WHERE note = 'a?b'`).Find(&[]Reading{})
+	})
+	if !strings.Contains(got, `note = 'a?b'`) {
+		t.Errorf("字符串字面量中的问号被替换: %s", got)
+	}
+}
+
+func TestGapFillMissingTimeRange(t *testing.T) {
+	db := openDryRun(t)
+	for name, tx := range map[string]*gorm.DB{
+		"没有时间条件": db.Model(&Reading{}),
+		"只有下界":   db.Model(&Reading{}).Where("time >= ?", time.Unix(0, 0)),
+		"OR 条件":  db.Model(&Reading{}).Where("time >= ? OR time < ?", time.Unix(0, 0), time.Unix(60, 0)),
+	} {
+		err := tx.Scopes(influxdb3gorm.GapFill(time.Minute, "time")).Find(&[]map[string]interface{}{}).Error
+		if !errors.Is(err, dialector.ErrMissingTimeRange) {
+			t.Errorf("%s: 期望 ErrMissingTimeRange，得到: %v", name, err)
+		}
+	}
+}
+
+func TestTimestampLiteralPrecision(t *testing.T) {
+	// 东八区的时间转换为 UTC，超出精度的部分被截断
+	ts := time.Date(2024, 1, 1, 8, 0, 0, 123456789, time.FixedZone("UTC+8", 8*3600))
+	three := 3
+	for _, tt := range []struct {
+		name   string
+		config dialector.Config
+		want   string
+	}{
+		{"默认纳秒", dialector.Config{}, "TIMESTAMP '2024-01-01T00:00:00.123456789Z'"},
+		{"DisableNanoTimestamps", dialector.Config{DisableNanoTimestamps: true}, "TIMESTAMP '2024-01-01T00:00:00.123456Z'"},
+		{"DefaultDatetimePrecision", dialector.Config{DefaultDatetimePrecision: &three, DisableNanoTimestamps: true}, "TIMESTAMP '2024-01-01T00:00:00.123Z'"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Database = "test"
+			tt.config.Conn = &dialector.InfluxDBConnPool{}
+			db, err := gorm.Open(influxdb3gorm.New(tt.config), &gorm.Config{})
+			if err != nil {
+				t.Fatalf("打开数据库失败: %v", err)
+			}
+			got := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				return tx.Scopes(influxdb3gorm.Between(ts, ts.Add(time.Hour))).Find(&[]Reading{})
+			})
+			if !strings.Contains(got, `"time" >= `+tt.want) {
+				t.Errorf("时间戳字面量不正确\n得到: %s\n期望包含: %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	influxdb3gorm "github.com/xiabin827/influxdb3-gorm-driver"
+	"github.com/xiabin827/influxdb3-gorm-driver/influxdb3test"
+	"gorm.io/gorm"
+)
+
+// streamData 三个数据点，配合 BatchSize 为 1 时分三个记录批次返回
+const streamData = `readings,sensor=a value=1 1000000000
+readings,sensor=a value=2 2000000000
+readings,sensor=a value=3 3000000000
+`
+
+// openSlowServer 每个记录批次只有一行，批次之间等待足够长的时间，
+// 只有客户端取消数据流时查询才会提前结束
+func openSlowServer(t *testing.T) (*influxdb3test.Server, *gorm.DB, context.Context) {
+	t.Helper()
+	srv, db := openServer(t, serverOptions{
+		Data:   streamData,
+		Server: influxdb3test.Options{BatchSize: 1, BatchInterval: time.Minute},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return srv, db, ctx
+}
+
+// waitQueriesDone 等待服务端上的查询全部结束
+func waitQueriesDone(t *testing.T, srv *influxdb3test.Server) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.ActiveQueries() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("数据流没有被取消，仍有 %d 个查询在发送结果", srv.ActiveQueries())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStreamBatches(t *testing.T) {
+	_, db := openServer(t, serverOptions{Data: streamData, Server: influxdb3test.Options{BatchSize: 1}})
+
+	var values []float64
+	for r, err := range influxdb3gorm.Stream[Reading](db.Order("time")) {
+		if err != nil {
+			t.Fatalf("流式读取失败: %v", err)
+		}
+		values = append(values, r.Value)
+	}
+	if len(values) != 3 || values[0] != 1 || values[2] != 3 {
+		t.Errorf("跨批次读取的结果不正确: %v", values)
+	}
+}
+
+func TestStreamEarlyBreak(t *testing.T) {
+	srv, db, ctx := openSlowServer(t)
+
+	count := 0
+	for r, err := range influxdb3gorm.Stream[Reading](db.WithContext(ctx).Order("time")) {
+		if err != nil {
+			t.Fatalf("流式读取失败: %v", err)
+		}
+		if r.Value != 1 {
+			t.Errorf("第一行不正确: %+v", r)
+		}
+		count++
+		break
+	}
+	if count != 1 {
+		t.Fatalf("期望读取 1 行，实际 %d 行", count)
+	}
+
+	// 提前结束迭代只取消本次查询的数据流，不影响调用方的 context
+	waitQueriesDone(t, srv)
+	if ctx.Err() != nil {
+		t.Errorf("调用方的 context 不应被取消: %v", ctx.Err())
+	}
+}
+
+func TestRowsEarlyClose(t *testing.T) {
+	srv, db, ctx := openSlowServer(t)
+
+	rows, err := db.WithContext(ctx).Model(&Reading{}).Order("time").Rows()
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if !rows.Next() {
+		t.Fatalf("读取第一行失败: %v", rows.Err())
+	}
+	var r Reading
+	if err := db.ScanRows(rows, &r); err != nil || r.Value != 1 {
+		t.Fatalf("第一行不正确: %+v, %v", r, err)
+	}
+
+	// 提前关闭结果集时取消数据流，不影响调用方的 context
+	if err := rows.Close(); err != nil {
+		t.Fatalf("关闭结果集失败: %v", err)
+	}
+	waitQueriesDone(t, srv)
+	if ctx.Err() != nil {
+		t.Errorf("调用方的 context 不应被取消: %v", ctx.Err())
+	}
+}
+
+func TestQueryArrowEarlyRelease(t *testing.T) {
+	srv, db, ctx := openSlowServer(t)
+
+	reader, err := influxdb3gorm.QueryArrow(ctx, db.Model(&Reading{}).Order("time"))
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if !reader.Next() || reader.Record().NumRows() != 1 {
+		t.Fatalf("读取第一个记录批次失败: %v", reader.Err())
+	}
+
+	// 仍有引用时不会取消数据流
+	reader.Retain()
+	reader.Release()
+	if n := srv.ActiveQueries(); n != 1 {
+		t.Fatalf("还有引用时数据流不应结束，进行中的查询: %d", n)
+	}
+
+	reader.Release()
+	waitQueriesDone(t, srv)
+	if ctx.Err() != nil {
+		t.Errorf("调用方的 context 不应被取消: %v", ctx.Err())
+	}
+}
+
+func TestQueryArrowRecords(t *testing.T) {
+	_, db := openServer(t, serverOptions{Data: cacheData, Server: influxdb3test.Options{BatchSize: 1}})
+
+	reader, err := influxdb3gorm.QueryArrow(context.Background(),
+		db.Model(&Reading{}).Select("sensor", "value", "time").Where("sensor = ?", "a").Order("time"))
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	defer reader.Release()
+
+	// 列按 SELECT 的顺序返回，直接引用表中的列时带有 iox::column::type 元数据
+	fields := reader.Schema().Fields()
+	if len(fields) != 3 || fields[0].Name != "sensor" || fields[1].Name != "value" || fields[2].Name != "time" {
+		t.Fatalf("Arrow schema 不正确: %v", reader.Schema())
+	}
+	for i, want := range []struct {
+		typ     arrow.Type
+		ioxType string
+	}{
+		{arrow.STRING, "iox::column_type::tag"},
+		{arrow.FLOAT64, "iox::column_type::field::float"},
+		{arrow.TIMESTAMP, "iox::column_type::timestamp"},
+	} {
+		got, _ := fields[i].Metadata.GetValue("iox::column::type")
+		if fields[i].Type.ID() != want.typ || got != want.ioxType {
+			t.Errorf("%s 列的类型不正确: %s, %q", fields[i].Name, fields[i].Type, got)
+		}
+	}
+
+	// 每个记录批次一行，值保持 Arrow 类型
+	var values []float64
+	var times []time.Time
+	for reader.Next() {
+		record := reader.Record()
+		if record.NumRows() != 1 {
+			t.Fatalf("记录批次的行数不正确: %d", record.NumRows())
+		}
+		if sensor := record.Column(0).(*array.String).Value(0); sensor != "a" {
+			t.Errorf("sensor 不正确: %s", sensor)
+		}
+		values = append(values, record.Column(1).(*array.Float64).Value(0))
+		ts := record.Column(2).(*array.Timestamp)
+		times = append(times, ts.Value(0).ToTime(ts.DataType().(*arrow.TimestampType).Unit))
+	}
+	if err := reader.Err(); err != nil {
+		t.Fatalf("读取记录批次失败: %v", err)
+	}
+	if len(values) != 2 || values[0] != 1 || values[1] != 2 || !times[0].Equal(time.Unix(1, 0)) || !times[1].Equal(time.Unix(2, 0)) {
+		t.Errorf("记录批次的内容不正确: %v, %v", values, times)
+	}
+}
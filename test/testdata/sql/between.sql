@@ -0,0 +1 @@
+SELECT * FROM "readings" WHERE "time" >= TIMESTAMP '2024-01-01T00:00:00.000000000Z' AND "time" < TIMESTAMP '2024-01-01T01:00:00.000000000Z'
@@ -0,0 +1 @@
+SELECT DISTINCT "sensor" FROM "readings"
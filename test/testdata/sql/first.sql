@@ -0,0 +1 @@
+SELECT * FROM "readings" WHERE sensor = 'a' ORDER BY "readings"."time" LIMIT 1
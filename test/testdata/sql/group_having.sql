@@ -0,0 +1 @@
+SELECT sensor, avg(value) AS avg FROM "readings" GROUP BY "sensor" HAVING avg(value) > 10
@@ -0,0 +1 @@
+SELECT "usage.idle" FROM "readings" WHERE "readings"."usage.idle" > 90
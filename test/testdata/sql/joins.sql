@@ -0,0 +1 @@
+SELECT readings.sensor, s.location FROM "readings" JOIN sensors s ON s.sensor = readings.sensor AND s.active = true
@@ -0,0 +1 @@
+SELECT * FROM "readings" ORDER BY "readings"."time" DESC LIMIT 1
@@ -0,0 +1 @@
+SELECT * FROM "readings" WHERE "time" >= now() - INTERVAL '1500 milliseconds' AND "time" <= now()
@@ -0,0 +1 @@
+SELECT * FROM "readings" LIMIT 10 OFFSET 20
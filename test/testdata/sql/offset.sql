@@ -0,0 +1 @@
+SELECT * FROM "readings" OFFSET 5
@@ -0,0 +1 @@
+SELECT * FROM "readings" ORDER BY time DESC,sensor
@@ -0,0 +1 @@
+SELECT "sensor" FROM "readings" WHERE count > 1
@@ -0,0 +1 @@
+SELECT readings.sensor,value FROM "iox"."readings"
@@ -0,0 +1 @@
+SELECT "sensor","value" FROM "readings"
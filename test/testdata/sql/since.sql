@@ -0,0 +1 @@
+SELECT * FROM "readings" WHERE "time" >= now() - INTERVAL '5400 seconds'
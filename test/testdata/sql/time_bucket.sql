@@ -0,0 +1 @@
+SELECT date_bin(INTERVAL '300 seconds', "time", TIMESTAMP '1970-01-01T00:00:00.000000000Z') AS "time", avg(value) AS value FROM "readings" WHERE "time" >= TIMESTAMP '2024-01-01T00:00:00.000000000Z' AND "time" < TIMESTAMP '2024-01-01T01:00:00.000000000Z' GROUP BY date_bin(INTERVAL '300 seconds', "time", TIMESTAMP '1970-01-01T00:00:00.000000000Z')
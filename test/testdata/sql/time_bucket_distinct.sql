@@ -0,0 +1 @@
+SELECT DISTINCT date_bin(INTERVAL '60 seconds', "time", TIMESTAMP '1970-01-01T00:00:00.000000000Z') AS "time", "sensor" FROM "readings" WHERE "time" >= TIMESTAMP '2024-01-01T00:00:00.000000000Z' AND "time" < TIMESTAMP '2024-01-01T01:00:00.000000000Z' GROUP BY date_bin(INTERVAL '60 seconds', "time", TIMESTAMP '1970-01-01T00:00:00.000000000Z')
@@ -0,0 +1 @@
+SELECT date_bin(INTERVAL '86400 seconds', "time", TIMESTAMP '1969-12-31T16:00:00.000000000Z') AS "day", sensor, max(value) AS value FROM "readings" GROUP BY "sensor",date_bin(INTERVAL '86400 seconds', "time", TIMESTAMP '1969-12-31T16:00:00.000000000Z')
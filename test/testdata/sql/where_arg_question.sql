@@ -0,0 +1 @@
+SELECT * FROM "readings" WHERE note = 'what?' AND sensor = 'a'
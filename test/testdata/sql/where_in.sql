@@ -0,0 +1 @@
+SELECT * FROM "readings" WHERE sensor IN ('a','b')
@@ -0,0 +1 @@
+SELECT * FROM "readings" WHERE note = 'x?' AND "what?" = 'it''s ''?''' AND sensor = 'a`b'
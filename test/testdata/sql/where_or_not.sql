@@ -0,0 +1 @@
+SELECT * FROM "readings" WHERE sensor = 'a' OR sensor = 'b' AND NOT count = 0
@@ -0,0 +1 @@
+SELECT * FROM "readings" WHERE sensor = 'it''s' AND value > 1.5
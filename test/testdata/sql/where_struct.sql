@@ -0,0 +1 @@
+SELECT * FROM "readings" WHERE "readings"."sensor" = 'a' AND "readings"."count" = 3
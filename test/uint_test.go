@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	influxdb3gorm "github.com/xiabin827/influxdb3-gorm-driver"
+	"github.com/xiabin827/influxdb3-gorm-driver/dialector"
+	"gorm.io/gorm"
+)
+
+// Counter 带有无符号整数 field 的模型
+type Counter struct {
+	Host  string    `gorm:"column:host;type:tag"`
+	Bytes uint64    `gorm:"column:bytes"`
+	Small uint8     `gorm:"column:small"`
+	Time  time.Time `gorm:"column:time"`
+}
+
+func TestUintWriteAndRead(t *testing.T) {
+	srv, db := openServer(t)
+	counter := Counter{Host: "a", Bytes: math.MaxUint64, Small: 7, Time: time.Unix(1, 0)}
+	if err := db.Create(&counter).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	want := "counters,host=a bytes=18446744073709551615u,small=7u 1000000000"
+	if lines := srv.Lines("counters"); len(lines) != 1 || lines[0] != want {
+		t.Fatalf("写入的行协议不正确\n得到: %q\n期望: %q", lines, want)
+	}
+
+	var found []Counter
+	if err := db.Where("bytes = ?", uint64(math.MaxUint64)).Find(&found).Error; err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(found) != 1 || found[0].Bytes != math.MaxUint64 || found[0].Small != 7 {
+		t.Fatalf("查询结果不正确: %+v", found)
+	}
+	queries := srv.Queries()
+	if last := queries[len(queries)-1].SQL; !strings.Contains(last, "bytes = 18446744073709551615") {
+		t.Errorf("查询参数不正确: %s", last)
+	}
+
+	for c, err := range influxdb3gorm.Stream[Counter](db.Model(&Counter{})) {
+		if err != nil {
+			t.Fatalf("流式读取失败: %v", err)
+		}
+		if c.Bytes != math.MaxUint64 {
+			t.Errorf("流式读取的值不正确: %+v", c)
+		}
+	}
+}
+
+func TestUintOverflow(t *testing.T) {
+	srv, db := openServer(t)
+	if err := srv.WriteLineProtocol("counters,host=a bytes=1u,small=300u 1\n"); err != nil {
+		t.Fatalf("准备测试数据失败: %v", err)
+	}
+
+	// 超出 uint8 的值不会被截断
+	var found []Counter
+	if err := db.Find(&found).Error; err == nil {
+		t.Errorf("期望溢出错误，得到: %+v", found)
+	}
+	for c, err := range influxdb3gorm.Stream[Counter](db.Model(&Counter{})) {
+		if !errors.Is(err, dialector.ErrValueOutOfRange) {
+			t.Errorf("期望 ErrValueOutOfRange，得到: %+v %v", c, err)
+		}
+		break
+	}
+
+	// 负数不能写入无符号整数字段
+	counter := Counter{Host: "a", Time: time.Unix(0, 1)}
+	err := db.Model(&counter).Update("bytes", -1).Error
+	if !errors.Is(err, dialector.ErrValueOutOfRange) {
+		t.Errorf("期望 ErrValueOutOfRange，得到: %v", err)
+	}
+	if lines := srv.Lines("counters"); len(lines) != 1 || !strings.Contains(lines[0], "bytes=1u") {
+		t.Errorf("数据不应被修改: %v", lines)
+	}
+}
+
+func TestUintDryRun(t *testing.T) {
+	db := openDryRun(t)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("bytes > ?", uint64(math.MaxInt64)+1).Find(&[]Counter{})
+	})
+	if want := `SELECT * FROM "counters" WHERE bytes > 9223372036854775808`; sql != want {
+		t.Errorf("生成的 SQL 不正确\n得到: %s\n期望: %s", sql, want)
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&Counter{}); err != nil {
+		t.Fatalf("解析模型失败: %v", err)
+	}
+	if got := db.Dialector.DataTypeOf(stmt.Schema.LookUpField("bytes")); got != "UINTEGER" {
+		t.Errorf("字段类型不正确: %s", got)
+	}
+}